@@ -6,4 +6,7 @@ client_secret = <your client secret>
 # the following are all optional, with default values shown
 # port = 443
 # scheme = https
-# client_credentials_url = https://login.relationalai.com/oauth/token
\ No newline at end of file
+# client_credentials_url = https://login.relationalai.com/oauth/token
+
+# the engine to use when --engine is not given, optional
+# engine = <default engine>
@@ -0,0 +1,284 @@
+// Copyright 2022-2023 RelationalAI, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+// Export of relations as Arrow IPC and Parquet files. Column types are
+// derived from the relation's metadata, so that eg: dates, datetimes and
+// fixed decimals map to the corresponding Arrow types. Values that have no
+// lossless Arrow representation, eg: int128, 128 bit fixed decimals and
+// rationals, are written as strings. The relation signature is stored in the
+// schema metadata.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/apache/arrow/go/v7/arrow"
+	"github.com/apache/arrow/go/v7/arrow/array"
+	"github.com/apache/arrow/go/v7/arrow/decimal128"
+	"github.com/apache/arrow/go/v7/arrow/ipc"
+	"github.com/apache/arrow/go/v7/arrow/memory"
+	"github.com/apache/arrow/go/v7/parquet"
+	"github.com/apache/arrow/go/v7/parquet/pqarrow"
+	"github.com/pkg/errors"
+	"github.com/relationalai/rai-sdk-go/rai"
+	"github.com/shopspring/decimal"
+)
+
+// Returns the file extension for the given --output-format.
+func outputFileExt(format string) (string, error) {
+	switch format {
+	case "arrow":
+		return ".arrow", nil
+	case "parquet":
+		return ".parquet", nil
+	}
+	return "", errors.Errorf("bad output format '%s', expected 'arrow' or 'parquet'", format)
+}
+
+// Describes how a relation column is converted to an Arrow column.
+type arrowColumn struct {
+	kind  columnKind
+	dtype arrow.DataType
+}
+
+// The maximum precision of an Arrow Decimal128 value.
+const maxDecimal128Precision = 38
+
+// Returns the number of decimal digits needed for any integer of the given
+// bit width, eg: 3 for 8 bits.
+func decimalDigits(bits int64) int32 {
+	max := new(big.Int).Lsh(big.NewInt(1), uint(bits-1))
+	return int32(len(max.String()))
+}
+
+// Returns the Decimal128 type of each FixedDecimal column of the given
+// relation, with its precision derived from the decimal's bit width, and nil
+// for all other columns, and for decimals too wide for Decimal128.
+func decimalTypes(r rai.Relation) []*arrow.Decimal128Type {
+	result := make([]*arrow.Decimal128Type, r.NumCols())
+	m, ok := r.(metadataRelation)
+	if !ok {
+		return result
+	}
+	for i, t := range m.Metadata() {
+		vt, ok := t.(rai.ValueType)
+		if !ok || i >= len(result) || len(vt) < 5 {
+			continue
+		}
+		if vt[0] == "rel" && vt[1] == "base" && vt[2] == "FixedDecimal" {
+			bits, ok := vt[3].(int64)
+			if !ok || bits < 1 || bits > 128 {
+				continue
+			}
+			scale, ok := vt[4].(int64)
+			if !ok {
+				continue
+			}
+			precision := decimalDigits(bits)
+			if precision > maxDecimal128Precision {
+				continue
+			}
+			result[i] = &arrow.Decimal128Type{Precision: precision, Scale: int32(scale)}
+		}
+	}
+	return result
+}
+
+// Returns the Arrow column types for the given relation.
+func arrowColumns(r rai.Relation) []arrowColumn {
+	kinds := columnKinds(r)
+	decimals := decimalTypes(r)
+	result := make([]arrowColumn, r.NumCols())
+	for i, t := range r.Signature() {
+		rt, ok := t.(reflect.Type)
+		if !ok {
+			rt = reflect.TypeOf(t) // constant column
+		}
+		result[i] = arrowColumn{kinds[i], arrowType(rt, kinds[i], decimals[i])}
+	}
+	return result
+}
+
+// Returns the Arrow type corresponding to the given relation column type.
+func arrowType(t reflect.Type, kind columnKind, dt *arrow.Decimal128Type) arrow.DataType {
+	switch kind {
+	case kindChar:
+		return arrow.BinaryTypes.String
+	case kindDate:
+		return arrow.FixedWidthTypes.Date32
+	case kindDateTime:
+		return &arrow.TimestampType{Unit: arrow.Millisecond, TimeZone: "UTC"}
+	}
+	if t == nil {
+		return arrow.BinaryTypes.String
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return arrow.FixedWidthTypes.Boolean
+	case reflect.Int8:
+		return arrow.PrimitiveTypes.Int8
+	case reflect.Int16:
+		return arrow.PrimitiveTypes.Int16
+	case reflect.Int32:
+		return arrow.PrimitiveTypes.Int32
+	case reflect.Int64:
+		return arrow.PrimitiveTypes.Int64
+	case reflect.Uint8:
+		return arrow.PrimitiveTypes.Uint8
+	case reflect.Uint16:
+		return arrow.PrimitiveTypes.Uint16
+	case reflect.Uint32:
+		return arrow.PrimitiveTypes.Uint32
+	case reflect.Uint64:
+		return arrow.PrimitiveTypes.Uint64
+	case reflect.Float32:
+		return arrow.PrimitiveTypes.Float32
+	case reflect.Float64:
+		return arrow.PrimitiveTypes.Float64
+	}
+	if t == rai.DecimalType && dt != nil {
+		return dt
+	}
+	return arrow.BinaryTypes.String
+}
+
+// Append the given relation value to the given column builder.
+func appendValue(b array.Builder, v interface{}, c arrowColumn) error {
+	if v == nil {
+		b.AppendNull()
+		return nil
+	}
+	switch bb := b.(type) {
+	case *array.StringBuilder:
+		bb.Append(formatValue(v, c.kind))
+		return nil
+	case *array.Date32Builder:
+		bb.Append(arrow.Date32FromTime(v.(time.Time)))
+		return nil
+	case *array.TimestampBuilder:
+		bb.Append(arrow.Timestamp(v.(time.Time).UnixMilli()))
+		return nil
+	case *array.Decimal128Builder:
+		dt := c.dtype.(*arrow.Decimal128Type)
+		n := v.(decimal.Decimal).Shift(dt.Scale).BigInt()
+		limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(dt.Precision)), nil)
+		if n.CmpAbs(limit) >= 0 {
+			return errors.Errorf("decimal value '%v' out of range", v)
+		}
+		bb.Append(decimal128.FromBigInt(n))
+		return nil
+	case *array.BooleanBuilder:
+		bb.Append(v.(bool))
+		return nil
+	case *array.Float32Builder:
+		bb.Append(v.(float32))
+		return nil
+	case *array.Float64Builder:
+		bb.Append(v.(float64))
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	switch bb := b.(type) {
+	case *array.Int8Builder:
+		bb.Append(int8(rv.Int()))
+	case *array.Int16Builder:
+		bb.Append(int16(rv.Int()))
+	case *array.Int32Builder:
+		bb.Append(int32(rv.Int()))
+	case *array.Int64Builder:
+		bb.Append(rv.Int())
+	case *array.Uint8Builder:
+		bb.Append(uint8(rv.Uint()))
+	case *array.Uint16Builder:
+		bb.Append(uint16(rv.Uint()))
+	case *array.Uint32Builder:
+		bb.Append(uint32(rv.Uint()))
+	case *array.Uint64Builder:
+		bb.Append(rv.Uint())
+	default:
+		return errors.Errorf("unsupported arrow column type '%s'", c.dtype)
+	}
+	return nil
+}
+
+// Returns an Arrow record containing the rows of the given relation.
+func newArrowRecord(r rai.Relation) (arrow.Record, error) {
+	sig, _ := json.Marshal(r.Signature().Strings())
+	columns := arrowColumns(r)
+	fields := make([]arrow.Field, len(columns))
+	for i, c := range columns {
+		fields[i] = arrow.Field{
+			Name:     fmt.Sprintf("col%d", i+1),
+			Type:     c.dtype,
+			Nullable: true,
+			Metadata: arrow.NewMetadata(
+				[]string{"rai:type"}, []string{r.Signature().Strings()[i]})}
+	}
+	md := arrow.NewMetadata([]string{"rai:signature"}, []string{string(sig)})
+	schema := arrow.NewSchema(fields, &md)
+	b := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer b.Release()
+	for rnum := 0; rnum < r.NumRows(); rnum++ {
+		for cnum, v := range r.Row(rnum) {
+			if err := appendValue(b.Field(cnum), v, columns[cnum]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b.NewRecord(), nil
+}
+
+// Write the given relation to the given file as an Arrow IPC file.
+func writeArrowFile(f *os.File, r rai.Relation) error {
+	rec, err := newArrowRecord(r)
+	if err != nil {
+		return err
+	}
+	defer rec.Release()
+	w, err := ipc.NewFileWriter(f, ipc.WithSchema(rec.Schema()))
+	if err != nil {
+		return err
+	}
+	if err := w.Write(rec); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// Write the given relation to the given file as a Parquet file.
+func writeParquetFile(f *os.File, r rai.Relation) error {
+	rec, err := newArrowRecord(r)
+	if err != nil {
+		return err
+	}
+	defer rec.Release()
+	props := pqarrow.NewArrowWriterProperties(pqarrow.WithStoreSchema())
+	// wrap the file, since closing the parquet writer closes its sink
+	sink := struct{ io.Writer }{f}
+	w, err := pqarrow.NewFileWriter(rec.Schema(), sink, parquet.NewWriterProperties(), props)
+	if err != nil {
+		return err
+	}
+	if err := w.Write(rec); err != nil {
+		return err
+	}
+	return w.Close()
+}
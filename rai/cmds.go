@@ -20,26 +20,59 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/rand"
+	"net/http"
 	"os"
+	"os/signal"
+	"os/user"
 	"path"
 	"path/filepath"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"text/template"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/relationalai/rai-sdk-go/rai"
 	"github.com/spf13/cobra"
+	"gopkg.in/ini.v1"
 )
 
 var ErrNoEngines = errors.New("no engines available")
 
+// Process exit codes, see README.md.
+const (
+	exitOK        = 0 // success
+	exitError     = 1 // command failed, eg: bad arguments, transport or HTTP error
+	exitAborted   = 2 // transaction was aborted
+	exitProblem   = 3 // transaction reported an error
+	exitIntegrity = 4 // transaction reported an integrity constraint violation
+	exitWarning   = 5 // transaction reported warnings, with --fail-on-warning
+	exitTimeout   = 6 // wait condition was not met before the timeout expired
+)
+
+// Banner status corresponding to each transaction exit code.
+var exitStatus = map[int]string{
+	exitError:     "Failed",
+	exitAborted:   "Aborted",
+	exitProblem:   "Error",
+	exitIntegrity: "Integrity constraint violation",
+	exitWarning:   "Warning",
+}
+
+// A command result that determines the exit code of a successful request.
+type exitCoder interface {
+	exitCode() int
+}
+
 func fatal(format string, args ...interface{}) {
 	msg := fmt.Sprintf(format, args...)
 	fmt.Fprintf(os.Stderr, "Error: %s\n", msg)
-	os.Exit(1)
+	os.Exit(exitError)
 }
 
 func baseSansExt(fname string) string {
@@ -58,17 +91,70 @@ func readFile(fname string) (string, error) {
 // Represents the state used when processing a command.
 type Action struct {
 	cmd    *cobra.Command
+	ctx    context.Context
 	quiet  bool
+	query  *jsonQuery
+	tmpl   *template.Template
 	client *rai.Client
 	start  time.Time
+	onExit []func() error // called by Exit, eg: to delete ephemeral engines
+	stdin  bool           // stdin has been read
 }
 
 func newAction(cmd *cobra.Command) *Action {
 	result := &Action{cmd: cmd, start: time.Now()}
 	result.quiet = result.getBool("quiet")
+	if expr := result.getString("query"); expr != "" {
+		q, err := parseQuery(expr)
+		if err != nil {
+			fatal(err.Error())
+		}
+		result.query = q
+	}
+	tmpl, err := getOutputTemplate(result)
+	if err != nil {
+		fatal(err.Error())
+	}
+	if tmpl != nil && result.query != nil {
+		fatal("--query and --template are mutually exclusive")
+	}
+	result.tmpl = tmpl
+	result.ctx = result.newContext()
 	return result
 }
 
+// Returns the command context, which is done when the process receives
+// SIGINT or SIGTERM, or when the --timeout expires. A second signal
+// terminates the process immediately.
+func (a *Action) newContext() context.Context {
+	ctx := a.cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var cancel context.CancelFunc
+	if timeout := a.getDuration("timeout"); timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop() // restore default signal handling
+		cancel()
+	}()
+	return ctx
+}
+
+// Read all of stdin, which can only be read once by each command.
+func (a *Action) readStdin() ([]byte, error) {
+	if a.stdin {
+		return nil, errors.New("stdin can only be read once")
+	}
+	a.stdin = true
+	return io.ReadAll(os.Stdin)
+}
+
 func (a *Action) Client() *rai.Client {
 	if a.client == nil {
 		a.client = a.newClient()
@@ -77,7 +163,21 @@ func (a *Action) Client() *rai.Client {
 }
 
 func (a *Action) Context() context.Context {
-	return a.cmd.Context()
+	return a.ctx
+}
+
+// Returns the action's client with a fresh context that times out after 30s,
+// for requests that must be made after the action's context is done, eg: to
+// clean up after an interrupt. The returned function restores the client's
+// context.
+func (a *Action) detachedClient() (*rai.Client, func()) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	c := a.Client()
+	c.SetContext(ctx)
+	return c, func() {
+		cancel()
+		c.SetContext(a.Context())
+	}
 }
 
 // Returns the bool value corresponding to the named flag.
@@ -86,6 +186,12 @@ func (a *Action) getBool(name string) bool {
 	return result
 }
 
+// Returns the duration value corresponding to the named flag.
+func (a *Action) getDuration(name string) time.Duration {
+	result, _ := a.cmd.Flags().GetDuration(name)
+	return result
+}
+
 // Returns the int value corresponding to the named flag.
 func (a *Action) getInt(name string) int {
 	result, _ := a.cmd.Flags().GetInt(name)
@@ -123,6 +229,18 @@ func (a *Action) getStringArray(name string) []string {
 	return result
 }
 
+// Expand a leading ~/ in the given file name, like rai.LoadConfigFile.
+func expandUser(fname string) (string, error) {
+	if strings.HasPrefix(fname, "~/") {
+		usr, err := user.Current()
+		if err != nil {
+			return "", err
+		}
+		return path.Join(usr.HomeDir, fname[2:]), nil
+	}
+	return fname, nil
+}
+
 func (a *Action) loadConfig() *rai.Config {
 	var cfg rai.Config
 	fname := a.getString("config")
@@ -166,26 +284,72 @@ func showJSON(v interface{}) {
 	e.Encode(v)
 }
 
-func (a *Action) showValue(v interface{}) {
+func (a *Action) showValue(v interface{}) error {
+	if a.query != nil && !isNil(v) {
+		return a.query.show(os.Stdout, v)
+	}
+	if a.tmpl != nil && !isNil(v) {
+		return a.tmpl.Execute(os.Stdout, v)
+	}
 	switch vv := v.(type) {
 	case string:
 		fmt.Println(rtrimEol(vv))
+		return nil
 	default:
 		if isNil(v) {
-			return
+			return nil
+		}
+		if r, ok := v.(*transactionResult); ok && r.outputFormat != "" {
+			return r.export()
 		}
 		format := a.getString("format")
 		switch format {
 		case "pretty":
+			if s, ok := v.(tableShowable); ok {
+				return s.showTable(os.Stdout, a.tableOptions())
+			}
 			if s, ok := v.(rai.Showable); ok {
 				s.Show()
-				return
+				return nil
+			}
+		case "csv", "tsv":
+			if s, ok := v.(delimitedShowable); ok {
+				comma, ext := ',', ".csv"
+				if format == "tsv" {
+					comma, ext = '\t', ".tsv"
+				}
+				return s.showDelimited(comma, ext)
+			}
+		case "table":
+			if isTabular(v) {
+				return writeTable(os.Stdout, v, a.tableOptions())
+			}
+		case "markdown", "html":
+			if s, ok := v.(reportShowable); ok {
+				if format == "markdown" {
+					return s.showMarkdown(os.Stdout)
+				}
+				return s.showHTML(os.Stdout)
 			}
+		case "ndjson":
+			return showNDJSON(v)
 		case "json":
 			break // default
 		}
 		showJSON(v)
 	}
+	return nil
+}
+
+// Returns the table options corresponding to the command's flags.
+func (a *Action) tableOptions() tableOptions {
+	opts := tableOptions{
+		sortBy:    a.getString("sort-by"),
+		noHeaders: a.getBool("no-headers")}
+	if columns := a.getString("columns"); columns != "" {
+		opts.columns = strings.Split(columns, ",")
+	}
+	return opts
 }
 
 func (a *Action) Append(format string, args ...interface{}) *Action {
@@ -208,60 +372,236 @@ func (a *Action) Start(format string, args ...interface{}) *Action {
 	return a
 }
 
+// Register a function to call before the action exits.
+func (a *Action) atExit(f func() error) {
+	a.onExit = append(a.onExit, f)
+}
+
+// Call the registered exit functions, most recent first, and return false if
+// any of them fail.
+func (a *Action) runExitFuncs() bool {
+	ok := true
+	for i := len(a.onExit) - 1; i >= 0; i-- {
+		if err := a.onExit[i](); err != nil {
+			a.Append("%s\n", rtrimEol(err.Error()))
+			ok = false
+		}
+	}
+	a.onExit = nil
+	return ok
+}
+
+// Answers if the given result is shown through the pager, which is only the
+// case for query results whose relations are shown inline.
+func (a *Action) isPaged(result interface{}) bool {
+	r, ok := result.(*transactionResult)
+	if !ok || r.outputDir != "" || r.outputFormat != "" {
+		return false
+	}
+	if a.query != nil || a.tmpl != nil {
+		return false
+	}
+	switch a.getString("format") {
+	case "pretty", "csv", "tsv", "markdown", "html":
+		return true
+	}
+	return false
+}
+
 // Update the action banner and exit.
 func (a *Action) Exit(result interface{}, err error) {
 	delta := time.Since(a.start).Seconds()
 	if err != nil {
 		a.Append("(%.1fs)\n%s\n", delta, rtrimEol(err.Error()))
-		os.Exit(1)
+		code := exitError
+		if e, ok := err.(exitCoder); ok {
+			code = e.exitCode()
+		}
+		a.runExitFuncs()
+		os.Exit(code)
 	} else {
-		a.Append("Ok (%.1fs)\n", delta)
-		a.showValue(result)
-		os.Exit(0)
+		code := exitOK
+		if r, ok := result.(exitCoder); ok {
+			code = r.exitCode()
+		}
+		if code == exitOK {
+			a.Append("Ok (%.1fs)\n", delta)
+		} else {
+			a.Append("%s (%.1fs)\n", exitStatus[code], delta)
+		}
+		// clean up first, so that it doesn't wait for the pager to exit
+		if !a.runExitFuncs() && code == exitOK {
+			code = exitError
+		}
+		stop := func() {}
+		if a.isPaged(result) {
+			stop = startPager()
+		}
+		err := a.showValue(result)
+		stop()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", rtrimEol(err.Error()))
+			code = exitError
+		}
+		os.Exit(code)
 	}
 }
 
-// Pick a random PROVISIONED engine.
-/*
-func pickRandomEngine(action *Action) string {
-	rsp, err := action.Client().ListEngines("state", "PROVISIONED")
-	if err != nil {
-		action.Exit(nil, err)
+// Engine sizes, from smallest to largest.
+var engineSizes = []string{"XS", "S", "M", "L", "XL"}
+
+func engineSizeRank(size string) int {
+	for i, item := range engineSizes {
+		if strings.EqualFold(item, size) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Returns the engines with the best key according to the given comparison,
+// where better(a, b) answers if a is better than b.
+func bestEngines(engines []rai.Engine, better func(a, b *rai.Engine) bool) []rai.Engine {
+	result := []rai.Engine{}
+	for i := range engines {
+		item := &engines[i]
+		switch {
+		case len(result) == 0 || better(item, &result[0]):
+			result = []rai.Engine{*item}
+		case !better(&result[0], item):
+			result = append(result, *item) // tie
+		}
+	}
+	return result
+}
+
+// Returns an error if the given engine policy is not one of the known
+// policies.
+func checkEnginePolicy(policy string) error {
+	switch {
+	case policy == "latest", policy == "random", policy == "smallest", policy == "largest":
+		return nil
+	case strings.HasPrefix(policy, "name-prefix="):
+		return nil
 	}
-	switch len(rsp) {
+	return errors.Errorf("bad engine policy '%s', expected one of: "+
+		"latest, random, name-prefix=<prefix>, smallest, largest", policy)
+}
+
+// Select an engine from the given PROVISIONED engines using the given
+// policy, and return an error if the policy doesn't select exactly one.
+func selectEngine(engines []rai.Engine, policy string) (string, error) {
+	if err := checkEnginePolicy(policy); err != nil {
+		return "", err
+	}
+	if len(engines) == 0 {
+		return "", ErrNoEngines
+	}
+	var result []rai.Engine
+	switch {
+	case policy == "latest":
+		result = bestEngines(engines, func(a, b *rai.Engine) bool {
+			return a.CreatedOn > b.CreatedOn
+		})
+	case policy == "random":
+		rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+		return engines[rnd.Intn(len(engines))].Name, nil
+	case strings.HasPrefix(policy, "name-prefix="):
+		prefix := strings.TrimPrefix(policy, "name-prefix=")
+		for _, item := range engines {
+			if strings.HasPrefix(item.Name, prefix) {
+				result = append(result, item)
+			}
+		}
+	case policy == "smallest" || policy == "largest":
+		for _, item := range engines {
+			if engineSizeRank(item.Size) < 0 {
+				return "", errors.Errorf("engine '%s' has unknown size '%s'", item.Name, item.Size)
+			}
+		}
+		result = bestEngines(engines, func(a, b *rai.Engine) bool {
+			if policy == "smallest" {
+				return engineSizeRank(a.Size) < engineSizeRank(b.Size)
+			}
+			return engineSizeRank(a.Size) > engineSizeRank(b.Size)
+		})
+	}
+	switch len(result) {
 	case 0:
-		action.Exit(nil, ErrNoEngines)
+		return "", errors.Errorf("no engines match engine policy '%s'", policy)
 	case 1:
-		return rsp[0].Name
+		return result[0].Name, nil
+	}
+	names := make([]string, len(result))
+	for i, item := range result {
+		names[i] = item.Name
 	}
-	ix := rand.Intn(len(rsp))
-	return rsp[ix].Name
+	return "", errors.Errorf("engine policy '%s' is ambiguous, it matches: %s",
+		policy, strings.Join(names, ", "))
 }
-*/
 
-// Pick the most recently created PROVISIONED engine.
-func pickLatestEngine(action *Action) string {
-	rsp, err := action.Client().ListEngines("state", "PROVISIONED")
+// Returns the `engine` key of the config profile, if any.
+func (a *Action) configEngine() (string, error) {
+	fname, err := expandUser(a.getString("config"))
 	if err != nil {
-		action.Exit(nil, err)
+		return "", err
 	}
-	var best *rai.Engine
-	for i := 0; i < len(rsp); i++ {
-		item := &rsp[i]
-		if best == nil || best.CreatedOn < item.CreatedOn {
-			best = item
-		}
+	info, err := ini.Load(fname)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to load config '%s'", fname)
 	}
-	if best == nil {
-		action.Exit(nil, ErrNoEngines)
+	profile := a.getString("profile")
+	if !info.HasSection(profile) {
+		return "", nil
 	}
-	return best.Name
+	return info.Section(profile).Key("engine").String(), nil
 }
 
-// todo: switch to `pickRandomEngine` when we have database compatibility,
-// currently its too likely to pick an incompatible engine.
+// Pick the engine to use when --engine is not given. An explicit
+// --engine-policy takes precedence, followed by the RAI_ENGINE env var, the
+// `engine` key of the config profile, and the default 'latest' policy.
 func pickEngine(action *Action) string {
-	return pickLatestEngine(action)
+	policy := action.getString("engine-policy")
+	if err := checkEnginePolicy(policy); err != nil {
+		fatal(err.Error())
+	}
+	if !action.cmd.Flags().Changed("engine-policy") {
+		if engine := os.Getenv("RAI_ENGINE"); engine != "" {
+			return engine
+		}
+		engine, err := action.configEngine()
+		if err != nil {
+			fatal(err.Error())
+		}
+		if engine != "" {
+			return engine
+		}
+	}
+	rsp, err := action.Client().ListEngines("state", "PROVISIONED")
+	if err != nil {
+		action.Exit(nil, err)
+	}
+	engine, err := selectEngine(rsp, policy)
+	if err != nil {
+		fatal(err.Error())
+	}
+	return engine
+}
+
+// Returns the engine given by --engine, or a new engine if --ephemeral-engine
+// is given, or else the engine picked by pickEngine.
+func commandEngine(action *Action) string {
+	engine := action.getString("engine")
+	size := action.getString("ephemeral-engine")
+	switch {
+	case size != "" && engine != "":
+		fatal("--engine and --ephemeral-engine are mutually exclusive")
+	case size != "":
+		return createEphemeralEngine(action, size)
+	case engine == "":
+		return pickEngine(action)
+	}
+	return engine
 }
 
 //
@@ -340,6 +680,157 @@ func deleteEngine(cmd *cobra.Command, args []string) {
 	action.Exit(nil, err)
 }
 
+// Answers if the given error is an HTTP 404 error.
+func isNotFound(err error) bool {
+	if e, ok := err.(rai.HTTPError); ok {
+		return e.StatusCode == http.StatusNotFound
+	}
+	return false
+}
+
+// The result of ensure-engine.
+type ensureEngineResult struct {
+	Status string      `json:"status"` // created, unchanged or replaced
+	Engine *rai.Engine `json:"engine"`
+}
+
+func (r *ensureEngineResult) Show() {
+	fmt.Printf("%s\n\n", r.Status)
+	rai.ShowJSON(r.Engine, 4)
+	fmt.Println()
+}
+
+// The longest time to wait for an engine to be provisioned when the command
+// has no --timeout.
+const engineProvisionTimeout = 30 * time.Minute
+
+// Answers if the given engine state means that the engine is going away, and
+// will never be provisioned.
+func isEngineGone(state string) bool {
+	switch state {
+	case "DEPROVISIONING", "DEPROVISIONED", "DELETING", "DELETED":
+		return true
+	}
+	return false
+}
+
+// Wait until the given engine is provisioned, for at most
+// engineProvisionTimeout if the command has no --timeout. A timeout is
+// reported as a plain error, since the timeout exit code is reserved for the
+// wait command.
+func waitEngineProvisioned(a *Action, name string, interval, limit time.Duration) (*rai.Engine, error) {
+	ctx, cancel := withDefaultTimeout(a.Context(), engineProvisionTimeout)
+	defer cancel()
+	cond := &waitCondition{field: "state", values: []string{"PROVISIONED"}}
+	get := func() (interface{}, error) {
+		rsp, err := a.Client().GetEngine(name)
+		if err == nil && isEngineGone(rsp.State) {
+			return nil, errors.Errorf("engine '%s' is in state %s", name, rsp.State)
+		}
+		return rsp, err
+	}
+	rsp, err := pollUntil(ctx, get, fmt.Sprintf("engine '%s'", name), cond, interval, limit)
+	if e, ok := err.(*waitTimeoutError); ok {
+		return nil, errors.New(e.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rsp.(*rai.Engine), nil
+}
+
+// Create the given engine if it doesn't exist, or replace it if its size
+// differs and --recreate is given, and wait for it to be provisioned.
+func ensureEngine(cmd *cobra.Command, args []string) {
+	// assert len(args) == 1
+	name := args[0]
+	action := newAction(cmd)
+	size := action.getString("size")
+	recreate := action.getBool("recreate")
+	interval, limit, err := getPollOptions(action)
+	if err != nil {
+		fatal(err.Error())
+	}
+	action.Start("Ensure engine '%s' size=%s", name, size)
+	c := action.Client()
+	status := "unchanged"
+	rsp, err := c.GetEngine(name)
+	switch {
+	case isNotFound(err):
+		status = "created"
+	case err != nil:
+		action.Exit(nil, err)
+	case isEngineGone(rsp.State):
+		action.Exit(nil, errors.Errorf("engine '%s' is in state %s", name, rsp.State))
+	case !strings.EqualFold(rsp.Size, size):
+		if !recreate {
+			action.Exit(nil, errors.Errorf(
+				"engine '%s' exists with size %s, use --recreate to replace it", name, rsp.Size))
+		}
+		if err := c.DeleteEngine(name); err != nil && !isNotFound(err) {
+			action.Exit(nil, err)
+		}
+		status = "replaced"
+	}
+	if status != "unchanged" {
+		if _, err := c.CreateEngineAsync(name, size); err != nil {
+			action.Exit(nil, err)
+		}
+	}
+	rsp, err = waitEngineProvisioned(action, name, interval, limit)
+	if err != nil {
+		action.Exit(nil, err)
+	}
+	action.Exit(&ensureEngineResult{status, rsp}, nil)
+}
+
+// The intervals between status polls of ephemeral engines, which are created
+// by commands whose --poll-interval and --poll-max options, if any, are for
+// other purposes.
+const (
+	enginePollInterval = 2 * time.Second
+	enginePollMax      = 30 * time.Second
+)
+
+// Returns a new, unique, name for an ephemeral engine.
+func ephemeralEngineName() string {
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return fmt.Sprintf("rai-cli-%s-%06x",
+		time.Now().UTC().Format("20060102-150405"), rnd.Intn(1<<24))
+}
+
+// Create an engine of the given size, wait for it to be provisioned and
+// delete it when the action exits, including on failure or interrupt.
+func createEphemeralEngine(a *Action, size string) string {
+	if engineSizeRank(size) < 0 {
+		fatal("bad engine size '%s', expected one of: %s", size, strings.Join(engineSizes, ", "))
+	}
+	name := ephemeralEngineName()
+	a.atExit(func() error { return deleteEphemeralEngine(a, name) })
+	a.Start("Create ephemeral engine '%s' size=%s", name, size)
+	if _, err := a.Client().CreateEngineAsync(name, size); err != nil {
+		a.Exit(nil, err)
+	}
+	if _, err := waitEngineProvisioned(a, name, enginePollInterval, enginePollMax); err != nil {
+		a.Exit(nil, err)
+	}
+	a.Append("Ok (%.1fs)\n", time.Since(a.start).Seconds())
+	a.start = time.Now()
+	return name
+}
+
+// Request the deletion of the given ephemeral engine.
+func deleteEphemeralEngine(a *Action, name string) error {
+	a.Start("Delete ephemeral engine '%s'", name)
+	c, restore := a.detachedClient()
+	defer restore()
+	if _, err := c.DeleteEngineAsync(name); err != nil && !isNotFound(err) {
+		return errors.Wrapf(err, "failed to delete ephemeral engine '%s'", name)
+	}
+	a.Append("Ok\n")
+	return nil
+}
+
 func getEngine(cmd *cobra.Command, args []string) {
 	// assert len(args) == 1
 	engine := args[0]
@@ -362,6 +853,154 @@ func listEngines(cmd *cobra.Command, args []string) {
 	action.Exit(rsp, err)
 }
 
+// An engine selected by prune-engines, and the outcome of deleting it.
+type pruneResult struct {
+	Name      string `json:"name"`
+	Size      string `json:"size"`
+	State     string `json:"state"`
+	CreatedBy string `json:"created_by"`
+	CreatedOn string `json:"created_on"`
+	Status    string `json:"status"` // would delete, deleting, deleted or failed
+	Error     string `json:"error,omitempty"`
+}
+
+type pruneResults []pruneResult
+
+func (r pruneResults) showTable(w io.Writer, opts tableOptions) error {
+	return writeTable(w, r, opts)
+}
+
+func (r pruneResults) exitCode() int {
+	for _, item := range r {
+		if item.Error != "" {
+			return exitError
+		}
+	}
+	return exitOK
+}
+
+// Answers if the given value matches one of the given items, ignoring case,
+// or if there are no items.
+func matchAny(items []string, value string) bool {
+	if len(items) == 0 {
+		return true
+	}
+	for _, item := range items {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the engines that match the prune-engines filters, in name order.
+func pruneCandidates(a *Action, engines []rai.Engine) ([]rai.Engine, error) {
+	olderThan := a.getDuration("older-than")
+	glob := a.getString("name-glob")
+	sizes := a.getStringArray("size")
+	creators := a.getStringArray("created-by")
+	result := []rai.Engine{}
+	for _, item := range engines {
+		if item.State == "DELETING" || item.State == "DELETED" {
+			continue
+		}
+		if glob != "" {
+			ok, err := path.Match(glob, item.Name)
+			if err != nil {
+				return nil, errors.Wrapf(err, "bad name glob '%s'", glob)
+			}
+			if !ok {
+				continue
+			}
+		}
+		if !matchAny(sizes, item.Size) || !matchAny(creators, item.CreatedBy) {
+			continue
+		}
+		if olderThan > 0 {
+			createdOn, err := time.Parse(time.RFC3339, item.CreatedOn)
+			if err != nil {
+				return nil, errors.Wrapf(err, "engine '%s' has bad created_on", item.Name)
+			}
+			if time.Since(createdOn) < olderThan {
+				continue
+			}
+		}
+		result = append(result, item)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// The maximum number of concurrent engine deletion requests.
+const pruneParallelism = 8
+
+// Delete the engines that match the given filters, or just show them unless
+// --yes is given.
+func pruneEngines(cmd *cobra.Command, args []string) {
+	// assert len(args) == 0
+	action := newAction(cmd)
+	state := action.getStringArray("state")
+	if action.getDuration("older-than") <= 0 && action.getString("name-glob") == "" &&
+		len(state) == 0 && len(action.getStringArray("size")) == 0 &&
+		len(action.getStringArray("created-by")) == 0 {
+		fatal("at least one of --older-than, --name-glob, --state, --size or --created-by is required")
+	}
+	yes := action.getBool("yes")
+	if yes {
+		action.Start("Prune engines")
+	} else {
+		action.Start("Prune engines (dry run, use --yes to delete)")
+	}
+	filters := map[string]interface{}{}
+	if len(state) > 0 {
+		filters["state"] = state
+	}
+	c := action.Client()
+	rsp, err := c.ListEngines(filters)
+	if err != nil {
+		action.Exit(nil, err)
+	}
+	engines, err := pruneCandidates(action, rsp)
+	if err != nil {
+		action.Exit(nil, err)
+	}
+	result := make(pruneResults, len(engines))
+	for i, item := range engines {
+		result[i] = pruneResult{
+			Name:      item.Name,
+			Size:      item.Size,
+			State:     item.State,
+			CreatedBy: item.CreatedBy,
+			CreatedOn: item.CreatedOn,
+			Status:    "would delete"}
+	}
+	if !yes {
+		action.Exit(result, nil)
+	}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, pruneParallelism)
+	for i := range result {
+		wg.Add(1)
+		go func(item *pruneResult) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			rsp, err := c.DeleteEngineAsync(item.Name)
+			switch {
+			case isNotFound(err): // already gone, or gone by the follow-up GetEngine
+				item.Status = "deleted"
+			case err != nil:
+				// keep the error on a single line, for the table
+				item.Status, item.Error = "failed", strings.Join(strings.Fields(err.Error()), " ")
+			default:
+				item.Status, item.State = "deleting", rsp.State
+			}
+		}(&result[i])
+	}
+	wg.Wait()
+	action.Exit(result, nil)
+}
+
 //
 // OAuth Clients
 //
@@ -497,11 +1136,14 @@ func listModels(cmd *cobra.Command, args []string) {
 func loadModel(cmd *cobra.Command, args []string) {
 	// assert len(args) == 2
 	database, fname := args[0], args[1]
-	r, err := os.Open(fname)
+	source, err := readFile(fname)
 	if err != nil {
 		fatal(err.Error())
 	}
 	action := newAction(cmd)
+	source = getSourceTemplate(action).render(fname, source)
+	renderOnly(action, source)
+	r := strings.NewReader(source)
 	mname := action.getString("model")
 	if mname == "" {
 		mname = baseSansExt(fname)
@@ -520,20 +1162,22 @@ func loadModels(cmd *cobra.Command, args []string) {
 	// assert len(args) >= 2
 	database := args[0]
 	action := newAction(cmd)
-	engine := action.getString("engine")
 	prefix := action.getString("prefix")
+	tmpl := getSourceTemplate(action)
+	var rendered strings.Builder
 	models := map[string]io.Reader{}
 	for _, arg := range args[1:] {
 		name := filepath.Join(prefix, baseSansExt(arg))
-		r, err := os.Open(arg)
+		source, err := readFile(arg)
 		if err != nil {
 			fatal(err.Error())
 		}
-		models[name] = r
-	}
-	if engine == "" {
-		engine = pickEngine(action)
+		source = tmpl.render(arg, source)
+		fmt.Fprintf(&rendered, "// %s\n%s\n", name, rtrimEol(source))
+		models[name] = strings.NewReader(source)
 	}
+	renderOnly(action, rendered.String())
+	engine := commandEngine(action)
 	action.Start("Load models '%s' (%s/%s)", strings.Join(mapKeys(models), ", "), database, engine)
 	_, err := action.Client().LoadModels(database, engine, models)
 	action.Exit(nil, err) // ignore response
@@ -543,34 +1187,359 @@ func loadModels(cmd *cobra.Command, args []string) {
 // Transactions
 //
 
-// Retrieve query source from command option or named source file.
-func getQuerySource(action *Action, args []string) string {
+// Retrieve query source from command option or named source files. Source
+// files are concatenated into a single query, where a file named '-' is read
+// from stdin, and the returned source map locates each file in the query.
+func getQuerySource(action *Action, args []string) (string, sourceMap) {
+	tmpl := getSourceTemplate(action)
 	source := action.getString("code")
 	if source != "" {
-		return source
+		return tmpl.render("code", source), nil
 	}
-	fname := action.getString("file")
-	if fname == "" {
+	fnames := action.getStringArray("file")
+	if len(fnames) == 0 {
 		fatal("nothing to execute")
 	}
-	var err error
-	if source, err = readFile(fname); err != nil {
+	var b strings.Builder
+	var smap sourceMap
+	for _, fname := range fnames {
+		var text string
+		var err error
+		if fname == "-" {
+			var data []byte
+			data, err = action.readStdin()
+			text, fname = string(data), stdinName
+		} else {
+			text, err = readFile(fname)
+		}
+		if err != nil {
+			fatal(err.Error())
+		}
+		smap.add(&b, fname, tmpl.render(fname, text))
+	}
+	return b.String(), smap
+}
+
+// Split the given 'key=value' string into its key and value.
+func splitKeyValue(s string) (string, string, bool) {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok || k == "" {
+		return "", "", false
+	}
+	return k, v, true
+}
+
+// Returns the named transaction inputs given by the --input and --input-file
+// options, eg:
+//
+//	--input='name=value' --input-file='name=data.json' --input-file='name=-'
+//
+// where an input file named '-' is read from stdin.
+func getInputs(a *Action) map[string]string {
+	result := map[string]string{}
+	add := func(name, value string) {
+		if _, ok := result[name]; ok {
+			fatal("duplicate input '%s'", name)
+		}
+		result[name] = value
+	}
+	for _, item := range a.getStringArray("input") {
+		name, value, ok := splitKeyValue(item)
+		if !ok {
+			fatal("bad input '%s', expected '<name>=<value>'", item)
+		}
+		add(name, value)
+	}
+	for _, item := range a.getStringArray("input-file") {
+		name, fname, ok := splitKeyValue(item)
+		if !ok || fname == "" {
+			fatal("bad input file '%s', expected '<name>=<path>'", item)
+		}
+		var data []byte
+		var err error
+		if fname == "-" {
+			data, err = a.readStdin()
+		} else {
+			data, err = os.ReadFile(fname)
+		}
+		if err != nil {
+			fatal(err.Error())
+		}
+		add(name, string(data))
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// Parse the --relation options into the list of signature prefixes used to
+// select the relations that are shown. Each option is a colon delimited
+// prefix, where a leading colon is shorthand for the `output` relation and
+// '_' matches any value, eg:
+//
+//	--relation=':foo' --relation='output:bar:_' --relation='rel:catalog'
+func getRelationFilters(a *Action) []rai.Signature {
+	var result []rai.Signature
+	for _, item := range a.getStringArray("relation") {
+		parts := strings.Split(item, ":")
+		if parts[0] == "" {
+			parts[0] = "output"
+		}
+		sig := make(rai.Signature, len(parts))
+		for i, part := range parts {
+			if part == "" {
+				fatal("bad relation '%s', expected '<name>:<name>..'", item)
+			}
+			sig[i] = part
+		}
+		result = append(result, sig)
+	}
+	return result
+}
+
+// Returns the transaction response wrapped with the options that control how
+// its relations are shown.
+func newResult(a *Action, rsp *rai.TransactionResponse) *transactionResult {
+	result := newTransactionResult(rsp, getRelationFilters(a), a.getBool("all-relations"))
+	result.failOnWarning = a.getBool("fail-on-warning")
+	result.outputDir = a.getString("output-dir")
+	result.outputFormat = getOutputFormat(a)
+	result.window = getWindowOptions(a)
+	return result
+}
+
+// Returns the validated row window options.
+func getWindowOptions(a *Action) windowOptions {
+	opts := windowOptions{
+		limit:    a.getInt("limit"),
+		offset:   a.getInt("offset"),
+		maxWidth: a.getInt("max-width"),
+		sorted:   a.getBool("sorted")}
+	for _, name := range []string{"limit", "offset", "max-width"} {
+		if value := a.getInt(name); value < 0 {
+			fatal("bad --%s '%d', must not be negative", name, value)
+		}
+	}
+	return opts
+}
+
+// Returns the validated value of the --output-format option. Also rejects
+// --format table, which is not supported for query results.
+func getOutputFormat(a *Action) string {
+	if a.getString("format") == "table" {
+		fatal("format table is not supported for query results")
+	}
+	format := a.getString("output-format")
+	if format == "" {
+		return ""
+	}
+	if _, err := outputFileExt(format); err != nil {
 		fatal(err.Error())
 	}
-	return source
+	if a.getString("output-dir") == "" {
+		fatal("--output-format requires --output-dir")
+	}
+	return format
+}
+
+// Answers if the given transaction is in a terminal state.
+func isTransactionComplete(tx *rai.Transaction) bool {
+	switch tx.State {
+	case rai.Completed, rai.Aborted:
+		return true
+	}
+	return false
+}
+
+// Returns the --poll-interval and --poll-max options, and an error if either
+// isn't positive or if --poll-max is less than --poll-interval.
+func getPollOptions(a *Action) (time.Duration, time.Duration, error) {
+	interval := a.getDuration("poll-interval")
+	limit := a.getDuration("poll-max")
+	if interval <= 0 {
+		return 0, 0, errors.Errorf("bad --poll-interval '%s', must be positive", interval)
+	}
+	if limit < interval {
+		return 0, 0, errors.Errorf("bad --poll-max '%s', must be at least --poll-interval", limit)
+	}
+	return interval, limit, nil
+}
+
+// Poll the given transaction until it completes. Polling starts at the
+// --poll-interval and backs off to 20% of the total run time, up to
+// --poll-max. If the action's context is done before the transaction
+// completes, eg: on interrupt or --timeout, the transaction is cancelled.
+func waitTransaction(a *Action, rsp *rai.TransactionResponse) (*rai.TransactionResponse, error) {
+	if isTransactionComplete(&rsp.Transaction) {
+		return rsp, nil // fast path
+	}
+	id := rsp.Transaction.ID
+	opts := rai.GetTransactionOptions{Results: true, Metadata: true, Problems: true}
+	interval, limit, err := getPollOptions(a)
+	if err != nil {
+		return nil, err
+	}
+	p := newProgress(a, &rsp.Transaction)
+	defer p.Clear()
+	p.Show()
+	tick := time.NewTicker(time.Second) // redraw elapsed time
+	defer tick.Stop()
+	pause := interval
+	for {
+		poll := time.After(pause)
+	wait:
+		for {
+			select {
+			case <-a.Context().Done():
+				return nil, cancelInFlight(a, id)
+			case <-tick.C:
+				p.Show()
+			case <-poll:
+				break wait
+			}
+		}
+		rsp, err := a.Client().GetTransaction(id, opts)
+		if err != nil {
+			if a.Context().Err() != nil {
+				return nil, cancelInFlight(a, id)
+			}
+			return nil, err
+		}
+		if isTransactionComplete(&rsp.Transaction) {
+			return rsp, nil
+		}
+		p.Update(rsp.Transaction.State)
+		pause = time.Since(a.start) / 5 // 20% of total run time
+		if pause < interval {
+			pause = interval
+		}
+		if pause > limit {
+			pause = limit
+		}
+	}
+}
+
+// Cancel the given in-flight transaction after the action's context is done,
+// and return an error describing why it was cancelled.
+func cancelInFlight(a *Action, id string) error {
+	reason := "interrupted"
+	if a.Context().Err() == context.DeadlineExceeded {
+		reason = "timeout expired"
+	}
+	c, restore := a.detachedClient()
+	defer restore()
+	if _, err := c.CancelTransaction(id); err != nil {
+		return errors.Wrapf(err, "%s, failed to cancel transaction '%s'", reason, id)
+	}
+	return errors.Errorf("%s, cancelled transaction '%s'", reason, id)
+}
+
+// Print the given rendered source and exit, if --render-only is given.
+func renderOnly(action *Action, source string) {
+	if action.getBool("render-only") {
+		fmt.Println(rtrimEol(source))
+		os.Exit(exitOK)
+	}
 }
 
 func execQuery(cmd *cobra.Command, args []string) {
 	action := newAction(cmd)
 	database := args[0]
-	source := getQuerySource(action, args)
+	source, smap := getQuerySource(action, args)
+	renderOnly(action, source)
+	getOutputFormat(action) // validate before executing
+	getWindowOptions(action)
+	getRelationFilters(action)
+	if _, _, err := getPollOptions(action); err != nil {
+		fatal(err.Error())
+	}
+	inputs := getInputs(action)
+	tags := action.getStringArray("tag")
 	readonly := action.getBool("readonly")
-	engine := action.getString("engine")
-	if engine == "" {
-		engine = pickEngine(action)
+	async := action.getBool("async")
+	if async && action.getString("ephemeral-engine") != "" {
+		fatal("--async and --ephemeral-engine are mutually exclusive")
 	}
+	engine := commandEngine(action)
 	action.Start("Executing query (%s/%s) readonly=%s", database, engine, strconv.FormatBool(readonly))
-	rsp, err := action.Client().Execute(database, engine, source, nil, readonly)
+	rsp, err := action.Client().ExecuteAsync(database, engine, source, inputs, readonly, tags...)
+	if err != nil {
+		action.Exit(nil, err)
+	}
+	if async {
+		action.Exit(rsp.Transaction.ID, nil)
+	}
+	rsp, err = waitTransaction(action, rsp)
+	if err != nil {
+		action.Exit(nil, err)
+	}
+	result := newResult(action, rsp)
+	result.sources = smap
+	action.Exit(result, nil)
+}
+
+func cancelTransaction(cmd *cobra.Command, args []string) {
+	// assert len(args) == 1
+	id := args[0]
+	action := newAction(cmd).Start("Cancel transaction '%s'", id)
+	rsp, err := action.Client().CancelTransaction(id)
+	action.Exit(rsp, err)
+}
+
+func getTransaction(cmd *cobra.Command, args []string) {
+	// assert len(args) == 1
+	id := args[0]
+	action := newAction(cmd).Start("Get transaction '%s'", id)
+	rsp, err := action.Client().GetTransaction(id)
+	if err != nil {
+		action.Exit(nil, err)
+	}
+	action.Exit(&rsp.Transaction, nil)
+}
+
+func getTransactionMetadata(cmd *cobra.Command, args []string) {
+	// assert len(args) == 1
+	id := args[0]
+	action := newAction(cmd).Start("Get transaction metadata '%s'", id)
+	rsp, err := action.Client().GetTransactionMetadata(id)
+	if err != nil {
+		action.Exit(nil, err)
+	}
+	action.Exit(&transactionMetadata{rsp}, nil)
+}
+
+func getTransactionProblems(cmd *cobra.Command, args []string) {
+	// assert len(args) == 1
+	id := args[0]
+	action := newAction(cmd).Start("Get transaction problems '%s'", id)
+	rsp, err := action.Client().GetTransactionProblems(id)
+	action.Exit(problemList(rsp), err)
+}
+
+// Retrieve the transaction along with its results, metadata and problems, and
+// show it the same way as `exec`.
+func getTransactionResults(cmd *cobra.Command, args []string) {
+	// assert len(args) == 1
+	id := args[0]
+	action := newAction(cmd)
+	getOutputFormat(action) // validate before fetching
+	getWindowOptions(action)
+	action.Start("Get transaction results '%s'", id)
+	opts := rai.GetTransactionOptions{Results: true, Metadata: true, Problems: true}
+	rsp, err := action.Client().GetTransaction(id, opts)
+	if err != nil {
+		action.Exit(nil, err)
+	}
+	action.Exit(newResult(action, rsp), nil)
+}
+
+func listTransactions(cmd *cobra.Command, args []string) {
+	// assert len(args) == 0
+	action := newAction(cmd)
+	tags := action.getStringArray("tag")
+	action.Start("List transactions tags=%s", strings.Join(tags, ","))
+	rsp, err := action.Client().ListTransactions(tags...)
 	action.Exit(rsp, err)
 }
 
@@ -664,7 +1633,6 @@ func loadCSV(cmd *cobra.Command, args []string) {
 	// assert len(args) == 2
 	action := newAction(cmd)
 	database, fname := args[0], args[1]
-	engine := action.getString("engine")
 	relation := action.getString("relation")
 	if relation == "" {
 		relation = baseSansExt(fname)
@@ -674,9 +1642,7 @@ func loadCSV(cmd *cobra.Command, args []string) {
 		fatal(err.Error())
 	}
 	opts := getCSVOptions(action)
-	if engine == "" {
-		engine = pickEngine(action)
-	}
+	engine := commandEngine(action)
 	action.Start("Load CSV '%s' (%s/%s)", relation, database, engine)
 	rsp, err := action.Client().LoadCSV(database, engine, relation, r, opts)
 	action.Exit(rsp, err) // ignore response
@@ -695,10 +1661,7 @@ func loadJSON(cmd *cobra.Command, args []string) {
 	if err != nil {
 		fatal(err.Error())
 	}
-	engine := action.getString("engine")
-	if engine == "" {
-		engine = pickEngine(action)
-	}
+	engine := commandEngine(action)
 	action.Start("Load JSON '%s' (%s/%s)", relation, database, engine)
 	rsp, err := action.Client().LoadJSON(database, engine, relation, data)
 	action.Exit(rsp, err) // ignore response
@@ -991,6 +1954,226 @@ func getSnowflakeDataStreamStatus(cmd *cobra.Command, args []string) {
 	action.Exit(rsp, err)
 }
 
+//
+// Wait
+//
+
+// A condition on a resource field, eg: `state=PROVISIONED`, `state!=PENDING`
+// or `state=CREATED,FAILED`, where the field is named as in --format table.
+type waitCondition struct {
+	field  string
+	values []string
+	negate bool
+}
+
+func parseWaitCondition(s string) (*waitCondition, error) {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || key == "" || value == "" {
+		return nil, errors.Errorf("bad condition '%s', expected 'field=value'", s)
+	}
+	result := &waitCondition{field: key, values: strings.Split(value, ",")}
+	if strings.HasSuffix(key, "!") {
+		result.field, result.negate = strings.TrimSuffix(key, "!"), true
+	}
+	if result.field == "" {
+		return nil, errors.Errorf("bad condition '%s', expected 'field=value'", s)
+	}
+	return result, nil
+}
+
+// Returns an error if the condition's field is not a column of the given
+// resource type.
+func (c *waitCondition) check(t reflect.Type) error {
+	_, err := findColumn(structColumns(t, "", nil), c.field)
+	return err
+}
+
+func (c *waitCondition) String() string {
+	op := "="
+	if c.negate {
+		op = "!="
+	}
+	return c.field + op + strings.Join(c.values, ",")
+}
+
+// Returns the value of the condition's field in the given resource.
+func (c *waitCondition) value(v interface{}) (string, error) {
+	rows, t, ok := tableRows(v)
+	if !ok || len(rows) != 1 {
+		return "", errors.Errorf("can't evaluate condition on value of type %T", v)
+	}
+	column, err := findColumn(structColumns(t, "", nil), c.field)
+	if err != nil {
+		return "", err
+	}
+	return formatCell(rows[0].FieldByIndex(column.index)), nil
+}
+
+// Answers if the condition holds for the given resource.
+func (c *waitCondition) match(v interface{}) (bool, error) {
+	value, err := c.value(v)
+	if err != nil {
+		return false, err
+	}
+	for _, item := range c.values {
+		if strings.EqualFold(value, item) {
+			return !c.negate, nil
+		}
+	}
+	return c.negate, nil
+}
+
+// The error returned when a wait condition is not met before the timeout.
+type waitTimeoutError struct {
+	name  string
+	cond  *waitCondition
+	value string
+}
+
+func (e *waitTimeoutError) Error() string {
+	return fmt.Sprintf("timeout expired waiting for %s %s (%s=%s)",
+		e.name, e.cond, e.cond.field, e.value)
+}
+
+func (e *waitTimeoutError) exitCode() int {
+	return exitTimeout
+}
+
+// The resource type of each kind of resource that can be waited on.
+var waitResourceTypes = map[string]reflect.Type{
+	"engine":                  reflect.TypeOf(rai.Engine{}),
+	"database":                reflect.TypeOf(rai.Database{}),
+	"snowflake-integration":   reflect.TypeOf(rai.Integration{}),
+	"snowflake-database-link": reflect.TypeOf(rai.SnowflakeDatabaseLink{}),
+	"snowflake-data-stream":   reflect.TypeOf(rai.SnowflakeDataStream{})}
+
+// Returns a function that reads the resource named by the given wait
+// arguments, a description of the resource and the resource type.
+func waitResource(a *Action, args []string) (func() (interface{}, error), string, reflect.Type) {
+	kind, names := args[0], args[1:]
+	nargs := map[string]int{
+		"engine":                  1,
+		"database":                1,
+		"snowflake-integration":   1,
+		"snowflake-database-link": 1,
+		"snowflake-data-stream":   3}
+	n, ok := nargs[kind]
+	if !ok {
+		fatal("unknown resource kind '%s', expected one of: engine, database, "+
+			"snowflake-integration, snowflake-database-link, snowflake-data-stream", kind)
+	}
+	if len(names) != n {
+		fatal("%s expects %d name argument(s), got %d", kind, n, len(names))
+	}
+	c := a.Client()
+	t := waitResourceTypes[kind]
+	switch kind {
+	case "engine":
+		return func() (interface{}, error) { return c.GetEngine(names[0]) },
+			fmt.Sprintf("engine '%s'", names[0]), t
+	case "database":
+		return func() (interface{}, error) { return c.GetDatabase(names[0]) },
+			fmt.Sprintf("database '%s'", names[0]), t
+	case "snowflake-integration":
+		return func() (interface{}, error) { return c.GetSnowflakeIntegration(names[0]) },
+			fmt.Sprintf("Snowflake integration '%s'", names[0]), t
+	case "snowflake-database-link":
+		database := a.getStringEnv("database", "SNOWSQL_DATABASE")
+		schema := a.getStringEnv("schema", "SNOWSQL_SCHEMA")
+		return func() (interface{}, error) {
+				return c.GetSnowflakeDatabaseLink(names[0], database, schema)
+			},
+			fmt.Sprintf("Snowflake database link '%s.%s' (%s)", database, schema, names[0]), t
+	}
+	return func() (interface{}, error) {
+			return c.GetSnowflakeDataStream(names[0], names[1], names[2])
+		},
+		fmt.Sprintf("Snowflake data stream '%s' (%s)", names[2], names[0]), t
+}
+
+// The longest time that wait polls a resource when the command has no
+// --timeout.
+const waitTimeout = 30 * time.Minute
+
+// Returns the given context, with the given timeout if it has no deadline.
+func withDefaultTimeout(
+	ctx context.Context, timeout time.Duration,
+) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Poll the given resource, starting at the given interval and backing off up
+// to the given limit, until the given condition holds, and return the
+// resource, or an error if the resource's state is a failed state or if the
+// given context is done first.
+func pollUntil(
+	ctx context.Context, get func() (interface{}, error), name string, cond *waitCondition,
+	interval, limit time.Duration,
+) (interface{}, error) {
+	value := ""
+	for pause := interval; ; {
+		rsp, err := get()
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return nil, err
+		}
+		ok, err := cond.match(rsp)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return rsp, nil
+		}
+		value, _ = cond.value(rsp)
+		if cond.field == "state" && strings.Contains(value, "FAILED") {
+			// terminal, like the SDK's isTerminalState
+			return nil, errors.Errorf("%s is in state %s", name, value)
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(pause):
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if pause = pause * 3 / 2; pause > limit {
+			pause = limit
+		}
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, &waitTimeoutError{name, cond, value}
+	}
+	return nil, errors.New("interrupted")
+}
+
+// Wait until the resource given by the arguments meets the condition given
+// by --for.
+func waitFor(cmd *cobra.Command, args []string) {
+	action := newAction(cmd)
+	cond, err := parseWaitCondition(action.getString("for"))
+	if err != nil {
+		fatal(err.Error())
+	}
+	interval, limit, err := getPollOptions(action)
+	if err != nil {
+		fatal(err.Error())
+	}
+	get, name, t := waitResource(action, args)
+	if err := cond.check(t); err != nil {
+		fatal(err.Error())
+	}
+	action.Start("Waiting for %s %s", name, cond)
+	ctx, cancel := withDefaultTimeout(action.Context(), waitTimeout)
+	defer cancel()
+	rsp, err := pollUntil(ctx, get, name, cond, interval, limit)
+	action.Exit(rsp, err)
+}
+
 //
 // Misc
 //
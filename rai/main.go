@@ -15,9 +15,46 @@
 package main
 
 import (
+	"os"
+	"time"
+
 	"github.com/spf13/cobra"
 )
 
+// Add the flags that control table formatted output.
+func addTableFlags(cmd *cobra.Command) {
+	cmd.Flags().String("columns", "", "comma separated list of table columns to show")
+	cmd.Flags().String("sort-by", "", "sort table rows by the given column")
+	cmd.Flags().Bool("no-headers", false, "don't show the table header row")
+}
+
+// Add the flag that runs the command on an engine created for it.
+func addEphemeralEngineFlag(cmd *cobra.Command) {
+	cmd.Flags().String("ephemeral-engine", "", "create an engine of the given size for the command, and delete it on exit")
+}
+
+// Add the flags that render rel sources as Go templates.
+func addSourceTemplateFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("var", nil, "template variable, 'key=value'")
+	cmd.Flags().String("vars-file", "", "file of template variables, one 'key=value' per line")
+	cmd.Flags().Bool("strict-vars", false, "fail on undefined template variables")
+	cmd.Flags().Bool("render-only", false, "print the rendered rel source without sending it")
+}
+
+// Add the flags that select and format the relations of transaction results.
+func addResultFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayP("relation", "r", nil, "show relations matching the given prefix, eg: ':foo' or 'rel:catalog'")
+	cmd.Flags().Bool("all-relations", false, "show all relations, not just output relations")
+	cmd.Flags().Bool("fail-on-warning", false, "exit with an error code if the transaction reports warnings")
+	cmd.Flags().String("output-dir", "", "write each relation to a file in the given directory")
+	cmd.Flags().Int("limit", 0, "max number of rows to show for each relation")
+	cmd.Flags().Int("offset", 0, "number of rows to skip for each relation")
+	cmd.Flags().Int("max-width", 0, "truncate string values longer than the given width")
+	cmd.Flags().Bool("sorted", false, "sort relations by signature and rows by value, and leave out "+
+		"the transaction id, timestamps and metadata, for stable output")
+	cmd.Flags().String("output-format", "", "write relation files as 'arrow' or 'parquet', requires --output-dir")
+}
+
 func addCommands(root *cobra.Command) {
 	// Databses
 	cmd := &cobra.Command{
@@ -53,6 +90,7 @@ func addCommands(root *cobra.Command) {
 		Short: "List all databases",
 		Run:   listDatabases}
 	cmd.Flags().StringArray("state", nil, "database state filter")
+	addTableFlags(cmd)
 	root.AddCommand(cmd)
 
 	// Engines
@@ -64,6 +102,17 @@ func addCommands(root *cobra.Command) {
 	cmd.Flags().String("size", "XS", "engine size (default: XS)")
 	root.AddCommand(cmd)
 
+	cmd = &cobra.Command{
+		Use:   "ensure-engine engine",
+		Short: "Create an engine if it doesn't exist, and wait for it to be provisioned",
+		Args:  cobra.ExactArgs(1),
+		Run:   ensureEngine}
+	cmd.Flags().String("size", "XS", "engine size (default: XS)")
+	cmd.Flags().Bool("recreate", false, "replace the engine if its size differs")
+	cmd.Flags().Duration("poll-interval", 2*time.Second, "initial interval between engine status polls")
+	cmd.Flags().Duration("poll-max", 30*time.Second, "maximum interval between engine status polls")
+	root.AddCommand(cmd)
+
 	cmd = &cobra.Command{
 		Use:   "delete-engine engine",
 		Short: "Delete an engine",
@@ -83,6 +132,21 @@ func addCommands(root *cobra.Command) {
 		Short: "List all engines",
 		Run:   listEngines}
 	cmd.Flags().StringArray("state", nil, "engine state filter")
+	addTableFlags(cmd)
+	root.AddCommand(cmd)
+
+	cmd = &cobra.Command{
+		Use:   "prune-engines",
+		Short: "Delete engines that match the given filters",
+		Args:  cobra.NoArgs,
+		Run:   pruneEngines}
+	cmd.Flags().Duration("older-than", 0, "select engines created more than the given duration ago, eg: '24h'")
+	cmd.Flags().String("name-glob", "", "select engines with names matching the given glob, eg: 'test-*'")
+	cmd.Flags().StringArray("state", nil, "engine state filter")
+	cmd.Flags().StringArray("size", nil, "engine size filter")
+	cmd.Flags().StringArray("created-by", nil, "engine creator filter")
+	cmd.Flags().Bool("yes", false, "delete the selected engines, instead of only showing them")
+	addTableFlags(cmd)
 	root.AddCommand(cmd)
 
 	// Models
@@ -117,6 +181,7 @@ func addCommands(root *cobra.Command) {
 		Run:   loadModel}
 	cmd.Flags().StringP("engine", "e", "", "default engine")
 	cmd.Flags().StringP("model", "m", "", "model name (default: file name)")
+	addSourceTemplateFlags(cmd)
 	root.AddCommand(cmd)
 
 	cmd = &cobra.Command{
@@ -125,7 +190,9 @@ func addCommands(root *cobra.Command) {
 		Args:  cobra.MinimumNArgs(2),
 		Run:   loadModels}
 	cmd.Flags().StringP("engine", "e", "", "default engine")
+	addEphemeralEngineFlag(cmd)
 	cmd.Flags().StringP("prefix", "p", "", "namespace prefix")
+	addSourceTemplateFlags(cmd)
 	root.AddCommand(cmd)
 
 	cmd = &cobra.Command{
@@ -178,6 +245,7 @@ func addCommands(root *cobra.Command) {
 		Use:   "list-oauth-clients",
 		Short: "List all OAuth clients",
 		Run:   listOAuthClients}
+	addTableFlags(cmd)
 	root.AddCommand(cmd)
 
 	// Transactions
@@ -187,9 +255,62 @@ func addCommands(root *cobra.Command) {
 		Args:  cobra.ExactArgs(1),
 		Run:   execQuery}
 	cmd.Flags().StringP("engine", "e", "", "default engine")
+	addEphemeralEngineFlag(cmd)
 	cmd.Flags().StringP("code", "c", "", "rel source code")
-	cmd.Flags().StringP("file", "f", "", "rel source file")
+	cmd.Flags().StringArrayP("file", "f", nil, "rel source file, '-' for stdin (repeatable)")
 	cmd.Flags().Bool("readonly", false, "transaction is read-only")
+	addSourceTemplateFlags(cmd)
+	cmd.Flags().Bool("async", false, "return the transaction id without waiting for completion")
+	cmd.Flags().Duration("poll-interval", 500*time.Millisecond, "initial interval between transaction status polls")
+	cmd.Flags().Duration("poll-max", 2*time.Minute, "maximum interval between transaction status polls")
+	cmd.Flags().StringArray("input", nil, "named input, 'name=value'")
+	cmd.Flags().StringArray("input-file", nil, "named input read from a file, 'name=path' ('-' for stdin)")
+	cmd.Flags().StringArray("tag", nil, "transaction tag, eg: 'job=nightly'")
+	addResultFlags(cmd)
+	root.AddCommand(cmd)
+
+	cmd = &cobra.Command{
+		Use:   "cancel-transaction id",
+		Short: "Cancel the given transaction",
+		Args:  cobra.ExactArgs(1),
+		Run:   cancelTransaction}
+	root.AddCommand(cmd)
+
+	cmd = &cobra.Command{
+		Use:   "get-transaction id",
+		Short: "Get information about the given transaction",
+		Args:  cobra.ExactArgs(1),
+		Run:   getTransaction}
+	root.AddCommand(cmd)
+
+	cmd = &cobra.Command{
+		Use:   "get-transaction-metadata id",
+		Short: "Get the output metadata of the given transaction",
+		Args:  cobra.ExactArgs(1),
+		Run:   getTransactionMetadata}
+	root.AddCommand(cmd)
+
+	cmd = &cobra.Command{
+		Use:   "get-transaction-problems id",
+		Short: "Get the problems reported by the given transaction",
+		Args:  cobra.ExactArgs(1),
+		Run:   getTransactionProblems}
+	root.AddCommand(cmd)
+
+	cmd = &cobra.Command{
+		Use:   "get-transaction-results id",
+		Short: "Get the results of the given transaction",
+		Args:  cobra.ExactArgs(1),
+		Run:   getTransactionResults}
+	addResultFlags(cmd)
+	root.AddCommand(cmd)
+
+	cmd = &cobra.Command{
+		Use:   "list-transactions",
+		Short: "List all transactions",
+		Args:  cobra.ExactArgs(0),
+		Run:   listTransactions}
+	cmd.Flags().StringArray("tag", nil, "transaction tag filter")
 	root.AddCommand(cmd)
 
 	cmd = &cobra.Command{
@@ -214,6 +335,7 @@ func addCommands(root *cobra.Command) {
 		Args:  cobra.ExactArgs(2),
 		Run:   loadCSV}
 	cmd.Flags().StringP("engine", "e", "", "default engine")
+	addEphemeralEngineFlag(cmd)
 	cmd.Flags().Int("header-row", -1, "header row number, 0 for no header (default: 1)")
 	cmd.Flags().String("delim", "", "field delimiter")
 	cmd.Flags().String("escapechar", "", "character used to escape quotes")
@@ -228,6 +350,7 @@ func addCommands(root *cobra.Command) {
 		Args:  cobra.MinimumNArgs(1),
 		Run:   loadJSON}
 	cmd.Flags().StringP("engine", "e", "", "default engine")
+	addEphemeralEngineFlag(cmd)
 	cmd.Flags().StringP("relation", "r", "", "relation name (default: file name)")
 	root.AddCommand(cmd)
 
@@ -279,6 +402,7 @@ func addCommands(root *cobra.Command) {
 		Use:   "list-users",
 		Short: "List all users",
 		Run:   listUsers}
+	addTableFlags(cmd)
 	root.AddCommand(cmd)
 
 	cmd = &cobra.Command{
@@ -335,6 +459,7 @@ func addCommands(root *cobra.Command) {
 		Short: "List all Snowflake integrations",
 		Args:  cobra.ExactArgs(0),
 		Run:   listSnowflakeIntegrations}
+	addTableFlags(cmd)
 	root.AddCommand(cmd)
 
 	// Snowflake database links
@@ -429,6 +554,7 @@ func addCommands(root *cobra.Command) {
 		Short: "List Snowflake data streams associated with an integration ",
 		Args:  cobra.ExactArgs(2),
 		Run:   listSnowflakeDataStreams}
+	addTableFlags(cmd)
 	root.AddCommand(cmd)
 
 	cmd = &cobra.Command{
@@ -438,6 +564,24 @@ func addCommands(root *cobra.Command) {
 		Run:   getSnowflakeDataStreamStatus}
 	root.AddCommand(cmd)
 
+	// Wait
+	cmd = &cobra.Command{
+		Use:   "wait kind name+",
+		Short: "Wait for a resource to meet the given condition, eg: 'wait engine name --for state=PROVISIONED'",
+		Long: "Wait for a resource to meet the given condition, where kind is one of: engine,\n" +
+			"database, snowflake-integration, snowflake-database-link (integration) or\n" +
+			"snowflake-data-stream (integration database-link objectName). Without\n" +
+			"--timeout, gives up after 30 minutes.",
+		Args: cobra.MinimumNArgs(2),
+		Run:  waitFor}
+	cmd.Flags().String("for", "", "condition, eg: 'state=PROVISIONED', 'state!=PENDING' or 'state=CREATED,FAILED'")
+	cmd.MarkFlagRequired("for")
+	cmd.Flags().Duration("poll-interval", 2*time.Second, "initial interval between polls")
+	cmd.Flags().Duration("poll-max", 30*time.Second, "maximum interval between polls")
+	cmd.Flags().String("database", "", "Snowflake database, for database links (default: SNOWSQL_DATABASE env var)")
+	cmd.Flags().String("schema", "", "Snowflake schema, for database links (default: SNOWSQL_SCHEMA env var)")
+	root.AddCommand(cmd)
+
 	// Misc
 	cmd = &cobra.Command{
 		Use:   "get-access-token",
@@ -453,7 +597,15 @@ func main() {
 	root.PersistentFlags().String("config", "~/.rai/config", "config file")
 	root.PersistentFlags().String("profile", "default", "config profile")
 	root.PersistentFlags().BoolP("quiet", "q", false, "silence status output")
-	root.PersistentFlags().String("format", "pretty", "format results, 'json' or 'pretty'")
+	root.PersistentFlags().String("format", "pretty", "format results, 'pretty', 'json', 'ndjson', 'table', 'csv', 'tsv', 'markdown' or 'html'")
+	root.PersistentFlags().String("query", "", "show the values selected by the given JSONPath-like expression, eg: '[*].name'")
+	root.PersistentFlags().String("template", "", "show results using the given Go template")
+	root.PersistentFlags().String("template-file", "", "show results using the Go template in the given file")
+	root.PersistentFlags().String("engine-policy", "latest", "how to pick an engine when --engine is not given, "+
+		"'latest', 'random', 'name-prefix=<prefix>', 'smallest' or 'largest'")
+	root.PersistentFlags().Duration("timeout", 0, "cancel the command after the given duration, eg: '10m'")
 	addCommands(root)
-	root.Execute()
+	if err := root.Execute(); err != nil {
+		os.Exit(exitError) // eg: bad arguments
+	}
 }
@@ -0,0 +1,61 @@
+// Copyright 2022-2023 RelationalAI, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+// Paging of long query results through $PAGER, when stdout is a terminal.
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Returns the pager command, from $PAGER, or `less` if it's available.
+func pagerCommand() string {
+	if pager, ok := os.LookupEnv("PAGER"); ok {
+		return pager
+	}
+	if _, err := exec.LookPath("less"); err == nil {
+		return "less -FRX"
+	}
+	return ""
+}
+
+// If stdout is a terminal, start the pager and redirect stdout to it, and
+// return a function that waits for the pager to exit and restores stdout.
+func startPager() func() {
+	pager := pagerCommand()
+	if pager == "" || pager == "cat" || !isTerminal(os.Stdout) {
+		return func() {}
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return func() {}
+	}
+	cmd := exec.Command("sh", "-c", pager)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = r, os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		r.Close()
+		w.Close()
+		return func() {}
+	}
+	r.Close()
+	stdout := os.Stdout
+	os.Stdout = w
+	return func() {
+		os.Stdout = stdout
+		w.Close()
+		cmd.Wait()
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2022-2023 RelationalAI, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/relationalai/rai-sdk-go/rai"
+)
+
+// Answers if the given file is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// A status line for an in-flight transaction that is redrawn in place on
+// stderr. Progress is only shown when stderr is a terminal and the action is
+// not quiet.
+type progress struct {
+	enabled bool
+	id      string
+	state   rai.TransactionState
+	start   time.Time
+}
+
+func newProgress(a *Action, tx *rai.Transaction) *progress {
+	return &progress{
+		enabled: !a.quiet && isTerminal(os.Stderr),
+		id:      tx.ID,
+		state:   tx.State,
+		start:   a.start}
+}
+
+// Update the transaction state and redraw the status line.
+func (p *progress) Update(state rai.TransactionState) {
+	p.state = state
+	p.Show()
+}
+
+// Redraw the status line.
+func (p *progress) Show() {
+	if !p.enabled {
+		return
+	}
+	delta := time.Since(p.start).Seconds()
+	fmt.Fprintf(os.Stderr, "\r\033[K%s %s (%.0fs)", p.id, p.state, delta)
+}
+
+// Erase the status line.
+func (p *progress) Clear() {
+	if !p.enabled {
+		return
+	}
+	fmt.Fprint(os.Stderr, "\r\033[K")
+}
@@ -0,0 +1,197 @@
+// Copyright 2022-2023 RelationalAI, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+// A small JSONPath-like query language for selecting values from command
+// results, eg: `$[0].name`, `.secret`, `[*].state`, `.relations[*].rows`.
+// Queries are evaluated against the JSON encoding of the result.
+//
+//   $           the root value (optional)
+//   .name       object member
+//   ['name']    object member, for names that aren't identifiers
+//   [n]         array element, negative indexes count from the end
+//   [*], .*     all array elements or object member values
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type querySegment struct {
+	name     string // object member name
+	index    int    // array index, if isIndex
+	isIndex  bool
+	wildcard bool
+}
+
+type jsonQuery struct {
+	expr     string
+	segments []querySegment
+	definite bool // selects at most a single value
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c == '-' || c == '$' || c == '@' ||
+		('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// Parse the given query expression.
+func parseQuery(expr string) (*jsonQuery, error) {
+	q := &jsonQuery{expr: expr, definite: true}
+	s := strings.TrimSpace(expr)
+	s = strings.TrimPrefix(s, "$")
+	for s != "" {
+		var seg querySegment
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			if strings.HasPrefix(s, "*") {
+				seg.wildcard, s = true, s[1:]
+				break
+			}
+			n := 0
+			for n < len(s) && isNameChar(s[n]) {
+				n++
+			}
+			if n == 0 {
+				return nil, errors.Errorf("bad query '%s': expected a name after '.'", expr)
+			}
+			seg.name, s = s[:n], s[n:]
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, errors.Errorf("bad query '%s': missing ']'", expr)
+			}
+			item := strings.TrimSpace(s[1:end])
+			s = s[end+1:]
+			switch {
+			case item == "*":
+				seg.wildcard = true
+			case len(item) >= 2 && (item[0] == '\'' || item[0] == '"') && item[len(item)-1] == item[0]:
+				seg.name = item[1 : len(item)-1]
+			default:
+				index, err := strconv.Atoi(item)
+				if err != nil {
+					return nil, errors.Errorf("bad query '%s': bad index '%s'", expr, item)
+				}
+				seg.index, seg.isIndex = index, true
+			}
+		default:
+			return nil, errors.Errorf("bad query '%s': unexpected '%c'", expr, s[0])
+		}
+		if seg.wildcard {
+			q.definite = false
+		}
+		q.segments = append(q.segments, seg)
+	}
+	return q, nil
+}
+
+// Returns the values selected by the given segment from the given value.
+func (seg *querySegment) eval(v interface{}) []interface{} {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		if seg.wildcard {
+			keys := make([]string, 0, len(vv))
+			for k := range vv {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			result := make([]interface{}, len(keys))
+			for i, k := range keys {
+				result[i] = vv[k]
+			}
+			return result
+		}
+		if item, ok := vv[seg.name]; ok && !seg.isIndex {
+			return []interface{}{item}
+		}
+	case []interface{}:
+		if seg.wildcard {
+			return vv
+		}
+		if seg.isIndex {
+			index := seg.index
+			if index < 0 {
+				index += len(vv)
+			}
+			if index >= 0 && index < len(vv) {
+				return []interface{}{vv[index]}
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the values selected by the query from the JSON encoding of the
+// given value.
+func (q *jsonQuery) eval(v interface{}) ([]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var root interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber() // preserve numbers as encoded
+	if err := d.Decode(&root); err != nil {
+		return nil, err
+	}
+	result := []interface{}{root}
+	for i := range q.segments {
+		next := []interface{}{}
+		for _, item := range result {
+			next = append(next, q.segments[i].eval(item)...)
+		}
+		result = next
+	}
+	if q.definite && len(result) == 0 {
+		return nil, errors.Errorf("query '%s' did not match", q.expr)
+	}
+	return result, nil
+}
+
+// Write the values selected by the query, one per line. Scalars are written
+// raw, so that they can be assigned directly to shell variables, and objects
+// and arrays as indented JSON.
+func (q *jsonQuery) show(w io.Writer, v interface{}) error {
+	items, err := q.eval(v)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		switch vv := item.(type) {
+		case nil:
+			fmt.Fprintln(w, "null")
+		case string:
+			fmt.Fprintln(w, vv)
+		case json.Number, bool:
+			fmt.Fprintln(w, vv)
+		default:
+			e := json.NewEncoder(w)
+			e.SetIndent("", "  ")
+			if err := e.Encode(vv); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+// Copyright 2022-2023 RelationalAI, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+// Markdown and HTML reports of query results, for pasting into PRs, wikis
+// and incident docs. Like rai.ShowRelation, each relation is shown as its
+// signature followed by its rows.
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/relationalai/rai-sdk-go/rai"
+)
+
+// A value that can be shown as a markdown or HTML report.
+type reportShowable interface {
+	showMarkdown(w io.Writer) error
+	showHTML(w io.Writer) error
+}
+
+// A relation, with its signature strings and formatted rows.
+type reportRelation struct {
+	Heading string
+	Columns []string
+	Rows    [][]string
+	Omitted string // footer describing omitted rows, if any
+}
+
+func newReportRelation(r rai.Relation) reportRelation {
+	sig := r.Signature().Strings()
+	kinds := columnKinds(r)
+	rows := make([][]string, r.NumRows())
+	for rnum := range rows {
+		rows[rnum] = formatRow(r, kinds, rnum)
+	}
+	result := reportRelation{Heading: strings.Join(sig, ", "), Columns: sig, Rows: rows}
+	if w, ok := r.(*relationWindow); ok && w.omitted() > 0 {
+		result.Omitted = fmt.Sprintf("%d of %d rows omitted", w.omitted(), w.Relation.NumRows())
+	}
+	return result
+}
+
+// Returns the selected relations of the result, prepared for a report.
+func (r *transactionResult) reportRelations() []reportRelation {
+	result := []reportRelation{}
+	if r.Metadata == nil {
+		return result
+	}
+	for _, rel := range r.outputs() {
+		result = append(result, newReportRelation(rel))
+	}
+	return result
+}
+
+// Returns the duration of the transaction, or 0 if it hasn't finished.
+func (r *transactionResult) duration() time.Duration {
+	tx := &r.Transaction
+	if tx.FinishedAt == 0 {
+		return 0
+	}
+	return time.Duration(tx.FinishedAt-tx.CreatedOn) * time.Millisecond
+}
+
+// Escape the given string for use in a GitHub-flavored markdown table cell.
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	s = strings.ReplaceAll(s, "|", "\\|")
+	s = strings.ReplaceAll(s, "\r\n", "<br>")
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
+
+func writeMarkdownRow(w io.Writer, cells []string) {
+	fmt.Fprint(w, "|")
+	for _, cell := range cells {
+		fmt.Fprintf(w, " %s |", markdownCell(cell))
+	}
+	fmt.Fprintln(w)
+}
+
+// Show each relation as a GitHub-flavored markdown table, with its
+// signature as a heading, followed by any problems.
+func (r *transactionResult) showMarkdown(w io.Writer) error {
+	for i, rel := range r.reportRelations() {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "### %s\n\n", markdownCell(rel.Heading))
+		writeMarkdownRow(w, rel.Columns)
+		sep := make([]string, len(rel.Columns))
+		for i := range sep {
+			sep[i] = "---"
+		}
+		writeMarkdownRow(w, sep)
+		for _, row := range rel.Rows {
+			writeMarkdownRow(w, row)
+		}
+		if rel.Omitted != "" {
+			fmt.Fprintf(w, "\n_%s_\n", rel.Omitted)
+		}
+	}
+	problems := r.sources.mapProblems(r.Problems)
+	if len(problems) > 0 {
+		fmt.Fprintln(w, "\n### Problems")
+		for i := range problems {
+			p := &problems[i]
+			fmt.Fprintf(w, "\n* **%s** (%s): %s\n", problemKind(p), p.ErrorCode, markdownCell(p.Message))
+			if p.Report != "" {
+				fmt.Fprintf(w, "\n  ```\n  %s\n  ```\n",
+					strings.ReplaceAll(rtrimEol(p.Report), "\n", "\n  "))
+			}
+		}
+	}
+	return nil
+}
+
+var htmlReport = template.Must(template.New("report").Funcs(template.FuncMap{
+	"problemKind": problemKind,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Transaction{{with .Transaction.ID}} {{.}}{{end}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #f4f4f4; font-weight: normal; font-family: monospace; }
+h2 { font-family: monospace; font-size: 1em; }
+pre { background: #f8f8f8; padding: 8px; }
+.error { color: #b00020; }
+.warning { color: #a66a00; }
+</style>
+</head>
+<body>
+<h1>Transaction{{with .Transaction.ID}} {{.}}{{end}}</h1>
+<table>
+<tr><th>database</th><td>{{.Transaction.Database}}</td></tr>
+<tr><th>state</th><td>{{.Transaction.State}}</td></tr>
+{{- if .Duration}}
+<tr><th>duration</th><td>{{.Duration}}</td></tr>
+{{- end}}
+</table>
+{{- range .Relations}}
+<h2>{{.Heading}}</h2>
+<table>
+<tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+{{- range .Rows}}
+<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{- end}}
+</table>
+{{- if .Omitted}}
+<p><i>{{.Omitted}}</i></p>
+{{- end}}
+{{- end}}
+{{- if .Problems}}
+<h2>Problems</h2>
+{{- range .Problems}}
+{{- $kind := problemKind .}}
+<p class="{{if eq $kind "Error"}}error{{else}}warning{{end}}"><b>{{$kind}}</b> ({{.ErrorCode}}): {{.Message}}</p>
+{{- if .Report}}
+<pre>{{.Report}}</pre>
+{{- end}}
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
+
+// Show the result as a self-contained HTML page with the transaction header,
+// each relation as a table and any problems.
+func (r *transactionResult) showHTML(w io.Writer) error {
+	problems := r.sources.mapProblems(r.Problems)
+	data := struct {
+		Transaction *rai.Transaction
+		Duration    time.Duration
+		Relations   []reportRelation
+		Problems    []*rai.Problem
+	}{&r.Transaction, r.duration(), r.reportRelations(), nil}
+	if r.window.sorted { // leave out the parts that differ on each run
+		tx := rai.Transaction{Database: r.Transaction.Database, State: r.Transaction.State}
+		data.Transaction, data.Duration = &tx, 0
+	}
+	for i := range problems {
+		data.Problems = append(data.Problems, &problems[i])
+	}
+	return htmlReport.Execute(w, &data)
+}
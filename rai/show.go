@@ -0,0 +1,439 @@
+// Copyright 2022-2023 RelationalAI, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+// Showable wrappers for SDK values that don't provide their own pretty
+// printer, or whose default JSON encoding is not useful.
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/relationalai/rai-sdk-go/rai"
+)
+
+// Transaction metadata, shown as the protobuf relation signatures.
+type transactionMetadata struct {
+	*rai.TransactionMetadata
+}
+
+func (m *transactionMetadata) Show() {
+	rai.ShowMetadata(m.Info)
+}
+
+// Encode metadata as a map of relation id => signature strings.
+func (m *transactionMetadata) MarshalJSON() ([]byte, error) {
+	result := map[string][]string{}
+	for id, sig := range m.Signatures() {
+		result[id] = sig.Strings()
+	}
+	return json.Marshal(result)
+}
+
+// Transaction problems, shown one problem per entry with its report.
+type problemList []rai.Problem
+
+// Returns the severity label for the given problem.
+func problemKind(p *rai.Problem) string {
+	if p.IsError || p.IsException {
+		return "Error"
+	}
+	return "Warning"
+}
+
+func (ps problemList) Show() {
+	ps.write(os.Stdout)
+}
+
+func (ps problemList) write(w io.Writer) {
+	for i := 0; i < len(ps); i++ {
+		p := &ps[i]
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s (%s): %s\n", problemKind(p), p.ErrorCode, p.Message)
+		if p.Report != "" {
+			fmt.Fprintln(w, rtrimEol(p.Report))
+		}
+	}
+}
+
+// A value that can be shown as delimited text, eg: CSV or TSV.
+type delimitedShowable interface {
+	showDelimited(comma rune, ext string) error
+}
+
+// Write the rows of the given relations as delimited text. If there is more
+// than one relation, the rows of each are headed by a "# <signature>" line.
+func writeDelimited(w io.Writer, rc rai.RelationCollection, comma rune) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	for _, r := range rc {
+		if len(rc) > 1 {
+			cw.Flush()
+			if err := cw.Error(); err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "# %s\n", r.Signature()); err != nil {
+				return err
+			}
+		}
+		kinds := columnKinds(r)
+		for rnum := 0; rnum < r.NumRows(); rnum++ {
+			if err := cw.Write(formatRow(r, kinds, rnum)); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Returns a file name for the given relation signature, eg: the signature
+// `("output", "foo", int64)` is named "output_foo_int64".
+func relationFileName(sig rai.Signature) string {
+	parts := sig.Strings()
+	for i, part := range parts {
+		part = strings.Trim(part, "\"")
+		parts[i] = strings.Trim(unsafeFileChars.ReplaceAllString(part, "-"), "-")
+	}
+	return strings.Join(parts, "_")
+}
+
+// Write each of the given relations to its own file in the given directory,
+// using the given write function, and return the list of file names.
+func writeRelationFiles(
+	dir, ext string, rc rai.RelationCollection, write func(*os.File, rai.Relation) error,
+) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	used := map[string]bool{}
+	result := []string{}
+	for _, r := range rc {
+		base := relationFileName(r.Signature())
+		name := base
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s-%d", base, i)
+		}
+		used[name] = true
+		fname := filepath.Join(dir, name+ext)
+		f, err := os.Create(fname)
+		if err != nil {
+			return nil, err
+		}
+		err = write(f, r)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to write '%s'", fname)
+		}
+		result = append(result, fname)
+	}
+	return result, nil
+}
+
+// A transaction response, along with the options that select which of its
+// relations are shown.
+type transactionResult struct {
+	*rai.TransactionResponse
+	filters       []rai.Signature // relation signature prefixes
+	all           bool            // show non-output relations
+	failOnWarning bool            // exit with an error code on warnings
+	sources       sourceMap       // locates problems in the query sources
+	outputDir     string          // write relations to files in this directory
+	outputFormat  string          // file format for exported relations
+	window        windowOptions   // rows of each relation to show
+}
+
+func newTransactionResult(
+	rsp *rai.TransactionResponse, filters []rai.Signature, all bool,
+) *transactionResult {
+	return &transactionResult{TransactionResponse: rsp, filters: filters, all: all}
+}
+
+// Answers if the given relation matches any of the result's filters.
+func (r *transactionResult) match(rel rai.Relation) bool {
+	for _, f := range r.filters {
+		if len(rai.RelationCollection{rel}.Select(f...)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the relations selected for output, which by default are the
+// relations under `output`.
+func (r *transactionResult) outputs() rai.RelationCollection {
+	var rc rai.RelationCollection
+	switch {
+	case len(r.filters) > 0:
+		rc = rai.RelationCollection{}
+		for _, rel := range r.Relations() {
+			if r.match(rel) {
+				rc = append(rc, rel)
+			}
+		}
+	case r.all:
+		rc = r.Relations()
+	default:
+		rc = r.Relations("output")
+	}
+	if r.window.isZero() {
+		return rc
+	}
+	// wrap a copy, rc may be the transaction's own relations
+	result := make(rai.RelationCollection, len(rc))
+	for i, rel := range rc {
+		result[i] = newRelationWindow(rel, r.window)
+	}
+	if r.window.sorted {
+		sortRelations(result)
+	}
+	return result
+}
+
+// Answers if the given problem is an integrity constraint violation.
+func isIntegrityViolation(p *rai.Problem) bool {
+	return p.Type == "IntegrityConstraintViolation"
+}
+
+// Returns the exit code corresponding to the transaction state and the
+// problems it reported, from most to least specific. Aborted transactions
+// that report errors exit with the code of their problems.
+func (r *transactionResult) exitCode() int {
+	var integrity, failed, warnings bool
+	for i := 0; i < len(r.Problems); i++ {
+		p := &r.Problems[i]
+		switch {
+		case isIntegrityViolation(p):
+			integrity = true
+		case p.IsError || p.IsException:
+			failed = true
+		default:
+			warnings = true
+		}
+	}
+	switch {
+	case integrity:
+		return exitIntegrity
+	case failed:
+		return exitProblem
+	case r.Transaction.State == rai.Aborted:
+		return exitAborted
+	case warnings && r.failOnWarning:
+		return exitWarning
+	}
+	return exitOK
+}
+
+func (r *transactionResult) Show() {
+	sep := "" // separates sections
+	// the transaction header differs on each run, so leave it out if sorted
+	if !r.window.sorted {
+		if err := rai.ShowJSON(&r.Transaction, 4); err != nil {
+			fmt.Println(errors.Wrapf(err, "failed to show transaction"))
+			return
+		}
+		sep = "\n"
+	}
+	if r.Metadata == nil {
+		return
+	}
+	rc := r.outputs()
+	if len(rc) > 0 {
+		fmt.Print(sep)
+		rc.Show()
+		sep = "\n"
+	}
+	if len(r.Problems) > 0 {
+		fmt.Printf("%sProblems:\n", sep)
+		problemList(r.sources.mapProblems(r.Problems)).Show()
+		return
+	}
+	if len(rc.Select("rel", "catalog", "diagnostic")) > 0 {
+		return // already shown, eg: with --all-relations
+	}
+	rc = r.Relations("rel", "catalog", "diagnostic")
+	if r.window.sorted {
+		sortRelations(rc)
+	}
+	if len(rc) > 0 {
+		fmt.Printf("%sProblems:\n", sep)
+		rai.ShowTabularData(rc.Union())
+	}
+}
+
+// Show the selected relations as delimited text, or write them to files in
+// the output directory, if given. Problems are written to stderr so that
+// stdout only contains relation data.
+func (r *transactionResult) showDelimited(comma rune, ext string) error {
+	if len(r.Problems) > 0 {
+		problemList(r.sources.mapProblems(r.Problems)).write(os.Stderr)
+	}
+	if r.Metadata == nil {
+		return nil
+	}
+	if r.outputDir == "" {
+		return writeDelimited(os.Stdout, r.outputs(), comma)
+	}
+	return r.writeFiles(ext, func(f *os.File, rel rai.Relation) error {
+		return writeDelimited(f, rai.RelationCollection{rel}, comma)
+	})
+}
+
+// Write each of the selected relations to a file in the output directory,
+// and show the names of the files written.
+func (r *transactionResult) writeFiles(
+	ext string, write func(*os.File, rai.Relation) error,
+) error {
+	fnames, err := writeRelationFiles(r.outputDir, ext, r.outputs(), write)
+	if err != nil {
+		return err
+	}
+	for _, fname := range fnames {
+		fmt.Println(fname)
+	}
+	return nil
+}
+
+// Export the selected relations as Arrow IPC or Parquet files, according to
+// the result's output format.
+func (r *transactionResult) export() error {
+	if len(r.Problems) > 0 {
+		problemList(r.sources.mapProblems(r.Problems)).write(os.Stderr)
+	}
+	if r.Metadata == nil {
+		return nil
+	}
+	ext, _ := outputFileExt(r.outputFormat)
+	write := writeArrowFile
+	if r.outputFormat == "parquet" {
+		write = writeParquetFile
+	}
+	return r.writeFiles(ext, write)
+}
+
+// A value that can be shown as a stream of JSON objects, one per line.
+type ndjsonShowable interface {
+	showNDJSON(e *json.Encoder) error
+}
+
+// Show the given value as newline delimited JSON, with one object per item
+// if the value is a list.
+func showNDJSON(v interface{}) error {
+	e := json.NewEncoder(os.Stdout)
+	if s, ok := v.(ndjsonShowable); ok {
+		return s.showNDJSON(e)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return e.Encode(v)
+	}
+	for i := 0; i < rv.Len(); i++ {
+		if err := e.Encode(rv.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A single relation tuple, as shown by the ndjson format.
+type tupleObject struct {
+	Signature []string      `json:"signature"`
+	Values    []interface{} `json:"values"`
+}
+
+// Show the transaction, followed by one object per tuple of the selected
+// relations, and then one object per problem.
+func (r *transactionResult) showNDJSON(e *json.Encoder) error {
+	if !r.window.sorted {
+		if err := e.Encode(map[string]interface{}{"transaction": &r.Transaction}); err != nil {
+			return err
+		}
+	}
+	if r.Metadata != nil {
+		for _, rel := range r.outputs() {
+			sig := rel.Signature().Strings()
+			kinds := columnKinds(rel)
+			for rnum := 0; rnum < rel.NumRows(); rnum++ {
+				t := tupleObject{sig, jsonRow(rel, kinds, rnum)}
+				if err := e.Encode(&t); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for _, p := range r.sources.mapProblems(r.Problems) {
+		if err := e.Encode(map[string]interface{}{"problem": p}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The JSON encoding of a relation.
+type relationObject struct {
+	Signature []string        `json:"signature"`
+	Rows      [][]interface{} `json:"rows"`
+}
+
+func newRelationObject(r rai.Relation) *relationObject {
+	kinds := columnKinds(r)
+	rows := make([][]interface{}, r.NumRows())
+	for rnum := range rows {
+		rows[rnum] = jsonRow(r, kinds, rnum)
+	}
+	return &relationObject{r.Signature().Strings(), rows}
+}
+
+// Encode the result as the transaction, the selected relations with their
+// typed rows, the problems and the transaction metadata.
+func (r *transactionResult) MarshalJSON() ([]byte, error) {
+	result := struct {
+		Transaction *rai.Transaction     `json:"transaction,omitempty"`
+		Relations   []*relationObject    `json:"relations"`
+		Problems    []rai.Problem        `json:"problems"`
+		Metadata    *transactionMetadata `json:"metadata,omitempty"`
+	}{
+		Relations: []*relationObject{},
+		Problems:  r.sources.mapProblems(r.Problems)}
+	if result.Problems == nil {
+		result.Problems = []rai.Problem{}
+	}
+	if !r.window.sorted { // leave out the parts that differ on each run
+		result.Transaction = &r.Transaction
+	}
+	if r.Metadata != nil {
+		for _, rel := range r.outputs() {
+			result.Relations = append(result.Relations, newRelationObject(rel))
+		}
+		if !r.window.sorted {
+			result.Metadata = &transactionMetadata{r.Metadata}
+		}
+	}
+	return json.Marshal(&result)
+}
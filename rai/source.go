@@ -0,0 +1,197 @@
+// Copyright 2022-2023 RelationalAI, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+// Support for query sources assembled from several files, where problem
+// locations reported against the combined source are mapped back to the
+// original file and line, and for rendering sources as Go templates.
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/relationalai/rai-sdk-go/rai"
+)
+
+const stdinName = "<stdin>"
+
+// A single file in a combined query source.
+type sourceFile struct {
+	name  string
+	line  int // first line in the combined source, 1-based
+	lines int // number of lines
+}
+
+// Maps lines of a combined query source to the files it was assembled from.
+type sourceMap []sourceFile
+
+// Append the given file contents to the combined source, and record its
+// location in the source map.
+func (m *sourceMap) add(b *strings.Builder, name, text string) {
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	line := 1
+	if n := len(*m); n > 0 {
+		last := (*m)[n-1]
+		line = last.line + last.lines
+	}
+	*m = append(*m, sourceFile{name, line, strings.Count(text, "\n")})
+	b.WriteString(text)
+}
+
+// Returns the file name and line number corresponding to the given line of
+// the combined source.
+func (m sourceMap) locate(line int) (string, int, bool) {
+	for _, f := range m {
+		if line >= f.line && line < f.line+f.lines {
+			return f.name, line - f.line + 1, true
+		}
+	}
+	return "", 0, false
+}
+
+// Matches the line number gutter of a problem report, eg: "12| def foo = ..".
+var reportLine = regexp.MustCompile(`(?m)^(\s*)(\d+)(\s*\|)`)
+
+// Returns a copy of the given problem with the line numbers in its report
+// mapped back to the original source files. The report is prefixed with the
+// file and line of the first reported location, which is also used as the
+// problem's path if it doesn't have one. If the report spans several files,
+// the lines of each later file are headed with its own file and line.
+func (m sourceMap) mapProblem(p rai.Problem) rai.Problem {
+	if len(m) == 0 {
+		return p
+	}
+	first, last := "", ""
+	p.Report = reportLine.ReplaceAllStringFunc(p.Report, func(s string) string {
+		parts := reportLine.FindStringSubmatch(s)
+		n, _ := strconv.Atoi(parts[2])
+		name, line, ok := m.locate(n)
+		if !ok {
+			return s
+		}
+		// pad to the original width to preserve the alignment of the report
+		num := fmt.Sprintf("%*d", len(parts[2]), line)
+		result := parts[1] + num + parts[3]
+		if first == "" {
+			first = fmt.Sprintf("%s:%d", name, line)
+		} else if name != last {
+			result = fmt.Sprintf("%s:%d\n%s", name, line, result)
+		}
+		last = name
+		return result
+	})
+	if first != "" {
+		p.Report = first + "\n" + p.Report
+		if p.Path == "" {
+			p.Path = first
+		}
+	}
+	return p
+}
+
+// Returns a copy of the given problems mapped back to the original sources.
+func (m sourceMap) mapProblems(ps []rai.Problem) []rai.Problem {
+	if len(m) == 0 || ps == nil {
+		return ps
+	}
+	result := make([]rai.Problem, len(ps))
+	for i, p := range ps {
+		result[i] = m.mapProblem(p)
+	}
+	return result
+}
+
+// Variables used to render Rel sources as Go text/template templates.
+type sourceTemplate struct {
+	vars   map[string]string
+	strict bool // fail on undefined variables
+}
+
+// Returns the source template options given by the --var, --vars-file,
+// --strict-vars and --render-only options, or nil if none of them are given,
+// in which case sources are not rendered.
+func getSourceTemplate(a *Action) *sourceTemplate {
+	vars := a.getStringArray("var")
+	fname := a.getString("vars-file")
+	strict := a.getBool("strict-vars")
+	if len(vars) == 0 && fname == "" && !strict && !a.getBool("render-only") {
+		return nil
+	}
+	result := &sourceTemplate{vars: map[string]string{}, strict: strict}
+	if fname != "" {
+		if err := readVarsFile(fname, result.vars); err != nil {
+			fatal(err.Error())
+		}
+	}
+	for _, item := range vars { // --var overrides --vars-file
+		k, v, ok := splitKeyValue(item)
+		if !ok {
+			fatal("bad var '%s', expected '<key>=<value>'", item)
+		}
+		result.vars[k] = v
+	}
+	return result
+}
+
+// Read 'key=value' lines from the named file into the given map, ignoring
+// blank lines and lines starting with '#'.
+func readVarsFile(fname string, vars map[string]string) error {
+	f, err := os.Open(fname)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, ok := splitKeyValue(line)
+		if !ok {
+			return fmt.Errorf("%s:%d: bad var '%s', expected '<key>=<value>'", fname, n, line)
+		}
+		vars[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return s.Err()
+}
+
+// Render the given source text, or return it unchanged if templating is not
+// enabled.
+func (t *sourceTemplate) render(name, text string) string {
+	if t == nil {
+		return text
+	}
+	missingkey := "missingkey=zero"
+	if t.strict {
+		missingkey = "missingkey=error"
+	}
+	tmpl, err := template.New(name).Option(missingkey).Parse(text)
+	if err != nil {
+		fatal(err.Error())
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, t.vars); err != nil {
+		fatal(err.Error())
+	}
+	return b.String()
+}
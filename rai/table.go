@@ -0,0 +1,273 @@
+// Copyright 2022-2023 RelationalAI, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+// Generic table rendering for SDK model structs, eg: rai.Engine. Columns are
+// named after the struct's JSON field names, and nested structs are flattened
+// into dotted names, eg: "snowflake.account".
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/relationalai/rai-sdk-go/rai"
+)
+
+type tableOptions struct {
+	columns   []string // columns to show, all if empty
+	sortBy    string   // column to sort rows by
+	noHeaders bool     // omit the header row
+}
+
+// A value whose pretty format is a table, eg: the result of prune-engines.
+type tableShowable interface {
+	showTable(w io.Writer, opts tableOptions) error
+}
+
+type tableColumn struct {
+	name  string
+	index []int // field index path, see reflect.Value.FieldByIndex
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// The package path of the SDK model structs.
+var modelPkgPath = reflect.TypeOf(rai.Engine{}).PkgPath()
+
+// Answers if the given struct type is a model type that can be shown as a
+// table, ie: an SDK model struct or a row of one of the CLI's own listings.
+func isModelType(t reflect.Type) bool {
+	return t.PkgPath() == modelPkgPath || t == reflect.TypeOf(pruneResult{})
+}
+
+// Returns the name of the given field, using its JSON name if it has one, and
+// "" if the field should not be shown.
+func fieldName(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+	name := f.Name
+	if tag, ok := f.Tag.Lookup("json"); ok {
+		tag, _, _ = strings.Cut(tag, ",")
+		if tag == "-" {
+			return ""
+		}
+		if tag != "" {
+			name = tag
+		}
+	}
+	return name
+}
+
+// Returns the list of columns corresponding to the fields of the given struct
+// type.
+func structColumns(t reflect.Type, prefix string, index []int) []tableColumn {
+	result := []tableColumn{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		findex := append(append([]int{}, index...), i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			result = append(result, structColumns(f.Type, prefix, findex)...)
+			continue
+		}
+		name := fieldName(f)
+		if name == "" {
+			continue
+		}
+		if f.Type.Kind() == reflect.Struct && f.Type != timeType {
+			result = append(result, structColumns(f.Type, prefix+name+".", findex)...)
+			continue
+		}
+		result = append(result, tableColumn{prefix + name, findex})
+	}
+	return result
+}
+
+// Returns the columns selected by the given list of names.
+func selectColumns(columns []tableColumn, names []string) ([]tableColumn, error) {
+	if len(names) == 0 {
+		return columns, nil
+	}
+	result := make([]tableColumn, len(names))
+	for i, name := range names {
+		c, err := findColumn(columns, name)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = c
+	}
+	return result, nil
+}
+
+func findColumn(columns []tableColumn, name string) (tableColumn, error) {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		if c.name == name {
+			return c, nil
+		}
+		names[i] = c.name
+	}
+	return tableColumn{}, errors.Errorf(
+		"unknown column '%s', expected one of: %s", name, strings.Join(names, ", "))
+}
+
+// Returns the table cell string for the given value.
+func formatCell(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return ""
+		}
+		return formatCell(v.Elem())
+	case reflect.Slice, reflect.Array:
+		items := make([]string, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			items[i] = formatCell(v.Index(i))
+		}
+		return strings.Join(items, ",")
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	return fmt.Sprintf("%v", v.Interface())
+}
+
+// Returns the rows of the given value, which must be a model struct or a slice
+// of model structs, and the struct type.
+func tableRows(v interface{}) ([]reflect.Value, reflect.Type, bool) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+	if rv.Kind() == reflect.Struct {
+		if !isModelType(rv.Type()) {
+			return nil, nil, false
+		}
+		return []reflect.Value{rv}, rv.Type(), true
+	}
+	if rv.Kind() != reflect.Slice {
+		return nil, nil, false
+	}
+	t := rv.Type().Elem()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || !isModelType(t) {
+		return nil, nil, false
+	}
+	rows := make([]reflect.Value, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		item := rv.Index(i)
+		for item.Kind() == reflect.Pointer {
+			if item.IsNil() {
+				break
+			}
+			item = item.Elem()
+		}
+		if item.Kind() == reflect.Struct {
+			rows = append(rows, item)
+		}
+	}
+	return rows, t, true
+}
+
+// Returns the value of the given cell used to sort rows, or nil if the cell
+// is empty, eg: a nil pointer or a zero time.
+func sortKey(v reflect.Value) interface{} {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	result := v.Interface()
+	if t, ok := result.(time.Time); ok && t.IsZero() {
+		return nil
+	}
+	return result
+}
+
+// Returns true if the given value can be shown as a table.
+func isTabular(v interface{}) bool {
+	_, _, ok := tableRows(v)
+	return ok
+}
+
+// Write the given struct, or slice of structs, as an aligned table.
+func writeTable(w io.Writer, v interface{}, opts tableOptions) error {
+	rows, t, ok := tableRows(v)
+	if !ok {
+		return errors.Errorf("can't show value of type %T as a table", v)
+	}
+	all := structColumns(t, "", nil)
+	columns, err := selectColumns(all, opts.columns)
+	if err != nil {
+		return err
+	}
+	cells := make([][]string, len(rows))
+	for i, row := range rows {
+		cells[i] = make([]string, len(columns))
+		for j, c := range columns {
+			cells[i][j] = formatCell(row.FieldByIndex(c.index))
+		}
+	}
+	if opts.sortBy != "" {
+		c, err := findColumn(all, opts.sortBy)
+		if err != nil {
+			return err
+		}
+		keys := make([]interface{}, len(rows))
+		for i, row := range rows {
+			keys[i] = sortKey(row.FieldByIndex(c.index))
+		}
+		perm := make([]int, len(rows))
+		for i := range perm {
+			perm[i] = i
+		}
+		sort.SliceStable(perm, func(i, j int) bool {
+			a, b := keys[perm[i]], keys[perm[j]]
+			if a == nil || b == nil {
+				return a != nil && b == nil // empty values last
+			}
+			return compareValues(a, b) < 0
+		})
+		sorted := make([][]string, len(cells))
+		for i, p := range perm {
+			sorted[i] = cells[p]
+		}
+		cells = sorted
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	if !opts.noHeaders {
+		header := make([]string, len(columns))
+		for i, c := range columns {
+			header[i] = strings.ToUpper(c.name)
+		}
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+	}
+	for _, row := range cells {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
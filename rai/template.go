@@ -0,0 +1,111 @@
+// Copyright 2022-2023 RelationalAI, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+// Go template output of command results, eg:
+//
+//   rai list-engines --template '{{range .}}{{.Name}} {{.State}}{{"\n"}}{{end}}'
+//
+// Templates are applied to the command's result value, so field names are
+// the Go field names of the SDK structs.
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Returns the given value as a time, where the value is a time, an RFC 3339
+// string or a number of milliseconds since the epoch.
+func asTime(v interface{}) (time.Time, error) {
+	switch vv := v.(type) {
+	case time.Time:
+		return vv, nil
+	case *time.Time:
+		return *vv, nil
+	case string:
+		return time.Parse(time.RFC3339, vv)
+	case int64:
+		return time.UnixMilli(vv).UTC(), nil
+	case int:
+		return time.UnixMilli(int64(vv)).UTC(), nil
+	}
+	return time.Time{}, errors.Errorf("can't convert '%v' (%T) to a time", v, v)
+}
+
+// Returns a string of at least the given width, with the value aligned left,
+// or right if the width is negative.
+func pad(width int, v interface{}) string {
+	if width < 0 {
+		return fmt.Sprintf("%*v", -width, v)
+	}
+	return fmt.Sprintf("%-*v", width, v)
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"jsonIndent": func(v interface{}) (string, error) {
+		data, err := json.MarshalIndent(v, "", "  ")
+		return string(data), err
+	},
+	"time": func(layout string, v interface{}) (string, error) {
+		t, err := asTime(v)
+		if err != nil {
+			return "", err
+		}
+		return t.Format(layout), nil
+	},
+	"age": func(v interface{}) (string, error) {
+		t, err := asTime(v)
+		if err != nil {
+			return "", err
+		}
+		return time.Since(t).Round(time.Second).String(), nil
+	},
+	"pad":   pad,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"join": func(sep string, v []string) string {
+		return strings.Join(v, sep)
+	},
+}
+
+// Returns the output template given by --template or --template-file, and
+// nil if neither is given.
+func getOutputTemplate(a *Action) (*template.Template, error) {
+	text := a.getString("template")
+	fname := a.getString("template-file")
+	switch {
+	case text != "" && fname != "":
+		return nil, errors.New("--template and --template-file are mutually exclusive")
+	case fname != "":
+		data, err := os.ReadFile(fname)
+		if err != nil {
+			return nil, err
+		}
+		text = string(data)
+	case text == "":
+		return nil, nil
+	}
+	return template.New("output").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+}
@@ -0,0 +1,266 @@
+// Copyright 2022-2023 RelationalAI, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+// Typed formatting of relation values.
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/relationalai/rai-sdk-go/rai"
+	"github.com/shopspring/decimal"
+)
+
+// Describes how the values of a relation column are formatted, for the cases
+// that can't be distinguished by the value's Go type.
+type columnKind int
+
+const (
+	kindValue    columnKind = iota
+	kindChar                // int32 code point
+	kindDate                // time.Time, date only
+	kindDateTime            // time.Time
+)
+
+// Relations that provide the metadata signature they were derived from.
+type metadataRelation interface {
+	Metadata() rai.Signature
+}
+
+// Returns the kind of each column of the given relation, based on the
+// relation's metadata signature, if available.
+func columnKinds(r rai.Relation) []columnKind {
+	result := make([]columnKind, r.NumCols())
+	m, ok := r.(metadataRelation)
+	if !ok {
+		return result
+	}
+	for i, t := range m.Metadata() {
+		if i >= len(result) {
+			break
+		}
+		switch tt := t.(type) {
+		case reflect.Type:
+			if tt == rai.CharType {
+				result[i] = kindChar
+			}
+		case rai.ValueType:
+			if len(tt) > 2 && tt[0] == "rel" && tt[1] == "base" {
+				switch tt[2] {
+				case "Date":
+					result[i] = kindDate
+				case "DateTime":
+					result[i] = kindDateTime
+				}
+			}
+		}
+	}
+	return result
+}
+
+// Returns the string representation of the given relation value, where
+// dates are formatted as ISO 8601, decimals and big integers in full
+// precision and rationals as 'num/den'.
+func formatValue(v interface{}, kind columnKind) string {
+	switch vv := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return vv
+	case bool:
+		return strconv.FormatBool(vv)
+	case int32:
+		if kind == kindChar {
+			return string(rune(vv))
+		}
+		return strconv.FormatInt(int64(vv), 10)
+	case float32:
+		return strconv.FormatFloat(float64(vv), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(vv, 'g', -1, 64)
+	case time.Time:
+		if kind == kindDate {
+			return vv.Format("2006-01-02")
+		}
+		return vv.Format(time.RFC3339Nano)
+	case decimal.Decimal:
+		return vv.String()
+	case *big.Int:
+		return vv.String()
+	case *big.Rat:
+		return vv.String() // always num/den, unlike RatString
+	case []interface{}: // value type
+		items := make([]string, len(vv))
+		for i, item := range vv {
+			items[i] = formatValue(item, kindValue)
+		}
+		return "(" + strings.Join(items, ", ") + ")"
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// Returns the formatted values of the given row of the given relation.
+func formatRow(r rai.Relation, kinds []columnKind, rnum int) []string {
+	row := r.Row(rnum)
+	result := make([]string, len(row))
+	for i, v := range row {
+		result[i] = formatValue(v, kinds[i])
+	}
+	return result
+}
+
+// Returns the JSON representation of the given relation value. Primitive
+// numbers are encoded as numbers, and decimals, big integers, rationals and
+// dates as strings, so that they are not truncated by JSON decoders.
+func jsonValue(v interface{}, kind columnKind) interface{} {
+	switch vv := v.(type) {
+	case int32:
+		if kind == kindChar {
+			return string(rune(vv))
+		}
+		return vv
+	case float32:
+		if math.IsNaN(float64(vv)) || math.IsInf(float64(vv), 0) {
+			return formatValue(v, kind)
+		}
+		return vv
+	case float64:
+		if math.IsNaN(vv) || math.IsInf(vv, 0) {
+			return formatValue(v, kind)
+		}
+		return vv
+	case time.Time, decimal.Decimal, *big.Int, *big.Rat:
+		return formatValue(v, kind)
+	case []interface{}: // value type
+		items := make([]interface{}, len(vv))
+		for i, item := range vv {
+			items[i] = jsonValue(item, kindValue)
+		}
+		return items
+	}
+	return v
+}
+
+// Returns the JSON values of the given row of the given relation.
+func jsonRow(r rai.Relation, kinds []columnKind, rnum int) []interface{} {
+	row := r.Row(rnum)
+	result := make([]interface{}, len(row))
+	for i, v := range row {
+		result[i] = jsonValue(v, kinds[i])
+	}
+	return result
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	case a == b:
+		return 0
+	case math.IsNaN(a) && math.IsNaN(b):
+		return 0
+	case math.IsNaN(a):
+		return -1
+	}
+	return 1
+}
+
+// Returns the order of the given relation values, comparing values of the
+// same type by value, and values of different types by type name.
+func compareValues(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	ta, tb := fmt.Sprintf("%T", a), fmt.Sprintf("%T", b)
+	if ta != tb {
+		return strings.Compare(ta, tb)
+	}
+	switch aa := a.(type) {
+	case string:
+		return strings.Compare(aa, b.(string))
+	case bool:
+		switch {
+		case aa == b.(bool):
+			return 0
+		case !aa:
+			return -1
+		}
+		return 1
+	case time.Time:
+		bb := b.(time.Time)
+		switch {
+		case aa.Before(bb):
+			return -1
+		case aa.After(bb):
+			return 1
+		}
+		return 0
+	case decimal.Decimal:
+		return aa.Cmp(b.(decimal.Decimal))
+	case *big.Int:
+		return aa.Cmp(b.(*big.Int))
+	case *big.Rat:
+		return aa.Cmp(b.(*big.Rat))
+	case []interface{}:
+		return compareRows(aa, b.([]interface{}))
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch va.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x, y := va.Int(), vb.Int()
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x, y := va.Uint(), vb.Uint()
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case reflect.Float32, reflect.Float64:
+		return compareFloats(va.Float(), vb.Float())
+	}
+	return strings.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+}
+
+// Returns the lexicographic order of the given rows.
+func compareRows(a, b []interface{}) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareValues(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
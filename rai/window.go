@@ -0,0 +1,231 @@
+// Copyright 2022-2023 RelationalAI, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+// Row windows over relations, for limiting the size of large results and
+// showing rows in a deterministic order. Note that rai.Relation.Slice selects
+// columns, not rows, so the window is implemented as a wrapper that maps row
+// numbers to the rows of the underlying relation.
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/relationalai/rai-sdk-go/rai"
+)
+
+// Options that select the rows of each relation that are shown.
+type windowOptions struct {
+	limit    int  // max rows, 0 for no limit
+	offset   int  // rows to skip
+	maxWidth int  // max string cell width, 0 for no limit
+	sorted   bool // sort rows by value
+}
+
+func (o windowOptions) isZero() bool {
+	return o.limit == 0 && o.offset == 0 && o.maxWidth == 0 && !o.sorted
+}
+
+// Returns the given string truncated to the given number of runes, with an
+// ellipsis marking the truncation.
+func truncate(s string, width int) string {
+	if width <= 0 {
+		return s
+	}
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	if width == 1 {
+		return "…"
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// Returns the given cell string, with its value truncated if the value is a
+// string. Other values, eg: numbers, are never truncated.
+func truncateCell(s string, v interface{}, width int) string {
+	str, ok := v.(string)
+	if !ok {
+		return s
+	}
+	t := truncate(str, width)
+	if t == str {
+		return s
+	}
+	if !strings.Contains(s, str) {
+		if u, err := strconv.Unquote(s); err == nil && u == str {
+			return strconv.Quote(t) // escaped by the cell format
+		}
+		return t
+	}
+	return strings.Replace(s, str, t, 1) // keep any quotes
+}
+
+// A column window, with row numbers relative to the window.
+type columnWindow struct {
+	rai.Column
+	rows     []int
+	maxWidth int
+}
+
+func (c columnWindow) NumRows() int {
+	return len(c.rows)
+}
+
+func (c columnWindow) String(rnum int) string {
+	rnum = c.rows[rnum]
+	return truncateCell(c.Column.String(rnum), c.Column.Value(rnum), c.maxWidth)
+}
+
+func (c columnWindow) Value(rnum int) interface{} {
+	v := c.Column.Value(c.rows[rnum])
+	if s, ok := v.(string); ok {
+		return truncate(s, c.maxWidth)
+	}
+	return v
+}
+
+// A relation window, that shows the given rows of the underlying relation.
+type relationWindow struct {
+	rai.Relation
+	rows     []int
+	maxWidth int
+}
+
+func newRelationWindow(r rai.Relation, opts windowOptions) *relationWindow {
+	rows := make([]int, r.NumRows())
+	for i := range rows {
+		rows[i] = i
+	}
+	if opts.sorted {
+		sortRows(r, rows)
+	}
+	lo := opts.offset
+	if lo > len(rows) {
+		lo = len(rows)
+	}
+	rows = rows[lo:]
+	if opts.limit > 0 && opts.limit < len(rows) {
+		rows = rows[:opts.limit]
+	}
+	return &relationWindow{r, rows, opts.maxWidth}
+}
+
+// Returns the number of rows of the underlying relation that are not shown.
+func (r *relationWindow) omitted() int {
+	return r.Relation.NumRows() - len(r.rows)
+}
+
+func (r *relationWindow) NumRows() int {
+	return len(r.rows)
+}
+
+func (r *relationWindow) Column(cnum int) rai.Column {
+	return columnWindow{r.Relation.Column(cnum), r.rows, r.maxWidth}
+}
+
+func (r *relationWindow) Columns() []rai.Column {
+	result := make([]rai.Column, r.NumCols())
+	for cnum := range result {
+		result[cnum] = r.Column(cnum)
+	}
+	return result
+}
+
+func (r *relationWindow) GetRow(rnum int, out []interface{}) {
+	r.Relation.GetRow(r.rows[rnum], out)
+	for i, v := range out {
+		if s, ok := v.(string); ok {
+			out[i] = truncate(s, r.maxWidth)
+		}
+	}
+}
+
+func (r *relationWindow) Row(rnum int) []interface{} {
+	result := make([]interface{}, r.NumCols())
+	r.GetRow(rnum, result)
+	return result
+}
+
+func (r *relationWindow) String(rnum int) string {
+	return "(" + strings.Join(r.Strings(rnum), ", ") + ")"
+}
+
+func (r *relationWindow) Strings(rnum int) []string {
+	result := make([]string, r.NumCols())
+	for cnum := range result {
+		result[cnum] = r.Column(cnum).String(rnum)
+	}
+	return result
+}
+
+func (r *relationWindow) Value(rnum int) interface{} {
+	return r.Row(rnum)
+}
+
+func (r *relationWindow) Slice(lo int, hi ...int) rai.Relation {
+	return &relationWindow{r.Relation.Slice(lo, hi...), r.rows, r.maxWidth}
+}
+
+// Returns the metadata signature of the underlying relation, if available.
+func (r *relationWindow) Metadata() rai.Signature {
+	if m, ok := r.Relation.(metadataRelation); ok {
+		return m.Metadata()
+	}
+	return nil
+}
+
+// Show the relation, followed by a footer with the number of omitted rows.
+func (r *relationWindow) Show() {
+	rai.ShowRelation(r)
+	if omitted := r.omitted(); omitted > 0 {
+		fmt.Printf("// %d of %d rows omitted\n", omitted, r.Relation.NumRows())
+	}
+}
+
+// Sort the given row numbers of the given relation by row value.
+func sortRows(r rai.Relation, rows []int) {
+	values := make([][]interface{}, r.NumRows())
+	for _, rnum := range rows {
+		values[rnum] = r.Row(rnum)
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return compareRows(values[rows[i]], values[rows[j]]) < 0
+	})
+}
+
+// Sort the given relations by signature, and then by row values.
+func sortRelations(rc rai.RelationCollection) {
+	sort.SliceStable(rc, func(i, j int) bool {
+		a, b := rc[i].Signature().Strings(), rc[j].Signature().Strings()
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		for rnum := 0; rnum < rc[i].NumRows() && rnum < rc[j].NumRows(); rnum++ {
+			if c := compareRows(rc[i].Row(rnum), rc[j].Row(rnum)); c != 0 {
+				return c < 0
+			}
+		}
+		return rc[i].NumRows() < rc[j].NumRows()
+	})
+}